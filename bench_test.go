@@ -0,0 +1,135 @@
+package teff
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type benchServer struct {
+	Name    string
+	Host    string
+	Port    int
+	Enabled bool
+	Weight  float64
+	Tags    []string
+}
+
+type benchConfig struct {
+	Title   string
+	Servers []benchServer
+	Limits  map[string]int
+}
+
+func newBenchConfig(n int) benchConfig {
+	c := benchConfig{Title: "bench", Limits: make(map[string]int, n)}
+	for i := 0; i < n; i++ {
+		s := strconv.Itoa(i)
+		c.Servers = append(c.Servers, benchServer{"server" + s, "10.0.0." + s, 8000 + i, i%2 == 0, float64(i) / 3, []string{"a", "b c"}})
+		c.Limits["limit"+s] = i
+	}
+	return c
+}
+
+func newBenchSlice(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// benchValues builds the values lazily, so the large ones cost nothing unless
+// their benchmark runs.
+var benchValues = []struct {
+	name  string
+	value func() interface{}
+}{
+	{"Scalar", func() interface{} { return 42 }},
+	{"String", func() interface{} { return "hello world" }},
+	{"SmallSlice", func() interface{} { return []int{1, 2, 3, 4, 5} }},
+	{"LargeSlice", func() interface{} { return newBenchSlice(10000) }},
+	{"Struct", func() interface{} { return newBenchConfig(1).Servers[0] }},
+	{"SmallNested", func() interface{} { return newBenchConfig(3) }},
+	{"LargeNested", func() interface{} { return newBenchConfig(1000) }},
+	{"SmallMap", func() interface{} { return newBenchConfig(3).Limits }},
+	{"LargeMap", func() interface{} { return newBenchConfig(10000).Limits }},
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, bv := range benchValues {
+		b.Run(bv.name, func(b *testing.B) {
+			value := bv.value()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := NewEncoder(ioutil.Discard).Encode(value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, bv := range benchValues {
+		b.Run(bv.name, func(b *testing.B) {
+			value := bv.value()
+			buf, err := Marshal(value)
+			if err != nil {
+				b.Fatal(err)
+			}
+			t := reflect.TypeOf(value)
+			b.SetBytes(int64(len(buf)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := Unmarshal(buf, reflect.New(t).Interface()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestAllocs guards against regressions of the allocations of small values. The
+// limits are the current counts with some headroom, and may need adjusting for
+// a new Go release, so it is skipped in short mode.
+func TestAllocs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation counts depend on the Go release")
+	}
+	for _, testcase := range []struct {
+		name      string
+		marshal   float64
+		unmarshal float64
+	}{
+		{"Scalar", 10, 20},
+		{"Struct", 90, 150},
+		{"SmallNested", 400, 600},
+	} {
+		var value interface{}
+		for _, bv := range benchValues {
+			if bv.name == testcase.name {
+				value = bv.value()
+			}
+		}
+		buf, err := Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		marshal := testing.AllocsPerRun(10, func() {
+			NewEncoder(ioutil.Discard).Encode(value)
+		})
+		unmarshal := testing.AllocsPerRun(10, func() {
+			Unmarshal(buf, reflect.New(reflect.TypeOf(value)).Interface())
+		})
+		if marshal > testcase.marshal {
+			t.Errorf("%s: expect at most %v allocations to marshal but got %v", testcase.name, testcase.marshal, marshal)
+		}
+		if unmarshal > testcase.unmarshal {
+			t.Errorf("%s: expect at most %v allocations to unmarshal but got %v", testcase.name, testcase.unmarshal, unmarshal)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package teff
+
+import (
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// marshalBlock encodes a multi-line string as a block string, i.e. a line of
+// core.BlockMarker followed by the indented lines of the string. It fails if
+// the string is a single line, ends with a line break, starts with a blank or
+// indented line, or contains characters other than char_inline.
+func marshalBlock(s string) (core.Node, bool) {
+	if !strings.Contains(s, "\n") || strings.HasSuffix(s, "\n") {
+		return core.Node{}, false
+	}
+	if s[0] == '\n' || s[0] == ' ' || s[0] == '\t' {
+		return core.Node{}, false
+	}
+	return blockNode(core.BlockMarker, strings.Split(s, "\n"))
+}
+
+// marshalFold encodes a single-line string longer than width as a folded block
+// string, i.e. a line of core.FoldMarker followed by the indented lines of the
+// string broken at spaces, each no longer than width unless it is a single
+// word. It fails if the string cannot be broken into more than one line, or
+// has leading, trailing or consecutive spaces.
+func marshalFold(s string, width int) (core.Node, bool) {
+	if len(s) <= width || strings.Contains(s, "\n") || strings.Contains(s, "  ") {
+		return core.Node{}, false
+	}
+	if s[0] == ' ' || s[0] == '\t' || s[len(s)-1] == ' ' {
+		return core.Node{}, false
+	}
+	var lines []string
+	for _, word := range strings.Split(s, " ") {
+		if n := len(lines); n > 0 && len(lines[n-1])+1+len(word) <= width {
+			lines[n-1] += " " + word
+		} else {
+			lines = append(lines, word)
+		}
+	}
+	if len(lines) < 2 {
+		return core.Node{}, false
+	}
+	return blockNode(core.FoldMarker, lines)
+}
+
+func blockNode(marker string, lines []string) (core.Node, bool) {
+	list := make(core.List, len(lines))
+	for i, line := range lines {
+		for _, r := range line {
+			if r < ' ' && r != '\t' || r == 0x7f {
+				return core.Node{}, false
+			}
+		}
+		list[i] = core.Node{Value: line}
+	}
+	return core.Node{Value: marker, List: list}, true
+}
+
+// blockString returns the string of a block string or folded block string
+// node.
+func blockString(node core.Node) (string, bool) {
+	if node.IsReference || len(node.List) == 0 {
+		return "", false
+	}
+	sep := ""
+	switch node.Value {
+	case core.BlockMarker:
+		sep = "\n"
+	case core.FoldMarker:
+		sep = " "
+	default:
+		return "", false
+	}
+	lines := make([]string, len(node.List))
+	for i, line := range node.List {
+		lines[i] = line.Value
+	}
+	return strings.Join(lines, sep), true
+}
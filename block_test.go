@@ -0,0 +1,81 @@
+package teff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBlock(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{"|", "|"},
+		{"a\n  b\n\n#c", "|\n\ta\n\t  b\n\n\t#c"},
+		{"a\n", `"a\n"`},
+		{" a\nb", `" a\nb"`},
+		{[]string{"a\n\tb", "c"}, "|\n\ta\n\t\tb\nc"},
+		{struct{ S string }{"a\nb"}, "S:\n\t|\n\t\ta\n\t\tb"},
+		{map[string]int{"a\nb": 1}, "\"a\\nb\":\n\t1"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestBlockDedent(t *testing.T) {
+	var s []string
+	text := "|\n    def f():\n        return 1\n\n    f()\n\n\nnext"
+	if err := Unmarshal([]byte(text), &s); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"def f():\n    return 1\n\nf()", "next"}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expect %q but got %q", expected, s)
+	}
+}
+
+func TestFold(t *testing.T) {
+	type doc struct {
+		Name        string
+		Description string
+	}
+	for i, testcase := range []struct {
+		value doc
+		text  string
+	}{
+		{doc{"a b c", "The quick brown fox jumps over the lazy dog"}, "Name:\n\ta b c\nDescription:\n\t>\n\t\tThe quick brown\n\t\tfox jumps over\n\t\tthe lazy dog"},
+		{doc{"", "Supercalifragilistic expialidocious"}, "Name:\n\t\"\"\nDescription:\n\t>\n\t\tSupercalifragilistic\n\t\texpialidocious"},
+		{doc{"", "two  spaces between the words"}, "Name:\n\t\"\"\nDescription:\n\ttwo  spaces between the words"},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetFoldWidth(16)
+		if err := enc.Encode(testcase.value); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, w.String())
+		}
+		var v doc
+		if err := Unmarshal(w.Bytes(), &v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v != testcase.value {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
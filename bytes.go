@@ -0,0 +1,83 @@
+package teff
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+// ByteEncoding is the encoding of a []byte or a [N]byte as a string.
+type ByteEncoding int
+
+const (
+	Base64 ByteEncoding = iota // standard base64 with padding, the default
+	Hex                        // lowercase hex, uppercase is also decoded
+)
+
+// SetByteEncoding sets the encoding of []byte and [N]byte values.
+func (enc *Encoder) SetByteEncoding(e ByteEncoding) {
+	enc.byteEncoding = e
+}
+
+// SetByteEncoding sets the encoding of []byte and [N]byte values, it should
+// match the one used by the Encoder.
+func (dec *Decoder) SetByteEncoding(e ByteEncoding) {
+	dec.byteEncoding = e
+}
+
+func isBytesType(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+func (enc *Encoder) marshalBytes(v reflect.Value) (core.Node, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return enc.nilNode(), nil
+	}
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	var s string
+	switch enc.byteEncoding {
+	case Base64:
+		s = base64.StdEncoding.EncodeToString(b)
+	case Hex:
+		s = hex.EncodeToString(b)
+	default:
+		return core.Node{}, fmt.Errorf("unknown byte encoding %d", enc.byteEncoding)
+	}
+	return enc.marshalNode(reflect.ValueOf(s))
+}
+
+func (dec *Decoder) unmarshalBytes(node core.Node, v reflect.Value) error {
+	if v.Kind() == reflect.Slice && dec.isNil(core.List{node}) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	s := unquote(node.Value)
+	var b []byte
+	var err error
+	switch dec.byteEncoding {
+	case Base64:
+		b, err = base64.StdEncoding.DecodeString(s)
+	case Hex:
+		b, err = hex.DecodeString(s)
+	default:
+		return fmt.Errorf("unknown byte encoding %d", dec.byteEncoding)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid bytes %s: %v", node.Value, err)
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), len(b), len(b)))
+	} else if len(b) != v.Len() {
+		return fmt.Errorf("expect %d bytes of %v but got %d", v.Len(), v.Type(), len(b))
+	}
+	for i, c := range b {
+		v.Index(i).SetUint(uint64(c))
+	}
+	return nil
+}
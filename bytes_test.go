@@ -0,0 +1,60 @@
+package teff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type digest [4]byte
+
+func TestBytes(t *testing.T) {
+	for i, testcase := range []struct {
+		encoding ByteEncoding
+		value    interface{}
+		text     string
+	}{
+		{Base64, []byte{1, 2, 255}, "AQL/"},
+		{Base64, []byte("hello"), "aGVsbG8="},
+		{Base64, []byte{}, `""`},
+		{Base64, []byte(nil), "nil"},
+		{Base64, digest{0xde, 0xad, 0xbe, 0xef}, "3q2+7w=="},
+		{Hex, []byte{1, 2, 255}, "0102ff"},
+		{Hex, []byte{0}, `"00"`},
+		{Hex, digest{0xde, 0xad, 0xbe, 0xef}, "deadbeef"},
+		{Hex, struct{ Sum digest }{digest{1, 2, 3, 4}}, "Sum:\n\t\"01020304\""},
+		{Hex, [][]byte{{0xab}, nil}, "ab\nnil"},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetByteEncoding(testcase.encoding)
+		if err := enc.Encode(testcase.value); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, w.String())
+		}
+		newValue := newValueOf(testcase.value)
+		dec := NewDecoder(&w)
+		dec.SetByteEncoding(testcase.encoding)
+		if err := dec.Decode(newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+	var d digest
+	if err := Unmarshal([]byte("AQI="), &d); err == nil {
+		t.Fatal("expect error for a wrong length of an array")
+	}
+	var b []byte
+	if err := Unmarshal([]byte("0102ff"), &b); err == nil {
+		t.Fatal("expect error for hex decoded as base64")
+	}
+	dec := NewDecoder(bytes.NewReader([]byte("DEADBEEF")))
+	dec.SetByteEncoding(Hex)
+	if err := dec.Decode(&d); err != nil || d != (digest{0xde, 0xad, 0xbe, 0xef}) {
+		t.Fatalf("expect uppercase hex decoded but got %v, %v", d, err)
+	}
+}
@@ -0,0 +1,73 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+// SetDrainChannels sets whether a buffered channel is encoded as a list of the
+// elements in its buffer.
+//
+// WARNING: encoding a channel is destructive, the buffered elements are
+// received from the channel and are NOT put back. It is meant for snapshots in
+// debugging tools where the channel is not used concurrently. An unbuffered
+// channel is always an error because its elements cannot be drained.
+func (enc *Encoder) SetDrainChannels(drain bool) {
+	enc.drainChannels = drain
+}
+
+func (enc *Encoder) marshalChan(v reflect.Value) (core.List, error) {
+	if !enc.drainChannels {
+		return nil, fmt.Errorf("channel %v is not encoded unless draining channels is enabled", v.Type())
+	}
+	if v.IsNil() {
+		return core.List{enc.nilNode()}, nil
+	}
+	if v.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, fmt.Errorf("cannot receive from channel %v", v.Type())
+	}
+	if v.Cap() == 0 {
+		return nil, fmt.Errorf("cannot drain unbuffered channel %v", v.Type())
+	}
+	list := core.List{}
+	for v.Len() > 0 {
+		elem, ok := v.TryRecv()
+		if !ok {
+			break
+		}
+		node, err := enc.marshalNode(elem)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, node)
+	}
+	return list, nil
+}
+
+// unmarshalChan decodes a list into a new buffered channel that holds exactly
+// the elements, or a channel with a buffer of one if the list is empty.
+func (dec *Decoder) unmarshalChan(list core.List, v reflect.Value) error {
+	if dec.isNil(list) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type().ChanDir() != reflect.BothDir {
+		return fmt.Errorf("cannot make channel %v", v.Type())
+	}
+	size := len(list)
+	if size == 0 {
+		size = 1
+	}
+	ch := reflect.MakeChan(v.Type(), size)
+	for _, node := range list {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := dec.unmarshalNode(node, elem); err != nil {
+			return err
+		}
+		ch.Send(elem)
+	}
+	v.Set(ch)
+	return nil
+}
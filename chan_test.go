@@ -0,0 +1,48 @@
+package teff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDrainChannels(t *testing.T) {
+	type snapshot struct {
+		Queue chan int
+		Idle  chan int
+	}
+	s := snapshot{Queue: make(chan int, 3)}
+	s.Queue <- 1
+	s.Queue <- 2
+	if _, err := Marshal(s); err == nil {
+		t.Fatal("expect error when draining channels is disabled")
+	}
+	if len(s.Queue) != 2 {
+		t.Fatalf("expect the channel to be untouched but got %d elements", len(s.Queue))
+	}
+
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetDrainChannels(true)
+	if err := enc.Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	text := "Queue:\n\t1\n\t2\nIdle:\n\tnil"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	if len(s.Queue) != 0 {
+		t.Fatalf("expect the channel to be drained but got %d elements", len(s.Queue))
+	}
+
+	var decoded snapshot
+	if err := Unmarshal(w.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Idle != nil || cap(decoded.Queue) != 2 || <-decoded.Queue != 1 || <-decoded.Queue != 2 {
+		t.Fatalf("expect a buffered channel of 1 and 2 but got %#v", decoded)
+	}
+
+	if err := enc.Encode(make(chan int)); err == nil {
+		t.Fatal("expect error for an unbuffered channel")
+	}
+}
@@ -0,0 +1,97 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// CheckType walks type t recursively and returns an error for the first type
+// that cannot be marshalled, without the need of a value.
+func CheckType(t reflect.Type) error {
+	return newTypeChecker().checkList(t)
+}
+
+type typeChecker struct {
+	visited map[reflect.Type]bool
+}
+
+func newTypeChecker() *typeChecker {
+	return &typeChecker{visited: make(map[reflect.Type]bool)}
+}
+
+func (c *typeChecker) checkList(t reflect.Type) error {
+	if globalRegistry.isLeafType(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Float32, reflect.Float64, reflect.String:
+		return nil
+	case reflect.Slice:
+		return c.checkNode(t.Elem())
+	case reflect.Struct:
+		return c.checkStruct(t)
+	case reflect.Map:
+		return c.checkMap(t)
+	case reflect.Ptr:
+		return c.checkList(t.Elem())
+	}
+	return fmt.Errorf("unsupported type: %v", t)
+}
+
+func (c *typeChecker) checkNode(t reflect.Type) error {
+	if globalRegistry.isLeafType(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Float32, reflect.Float64, reflect.String:
+		return nil
+	case reflect.Struct:
+		return c.checkStruct(t)
+	case reflect.Map:
+		return c.checkMap(t)
+	case reflect.Ptr:
+		return c.checkNode(t.Elem())
+	}
+	return fmt.Errorf("unsupported type: %v", t)
+}
+
+func (c *typeChecker) checkMap(t reflect.Type) error {
+	switch t.Key().Kind() {
+	case reflect.Bool, reflect.Int, reflect.String:
+	default:
+		return fmt.Errorf("unsupported map key type: %v", t.Key())
+	}
+	return c.checkList(t.Elem())
+}
+
+func (c *typeChecker) checkStruct(t reflect.Type) error {
+	if c.visited[t] {
+		return nil
+	}
+	c.visited[t] = true
+	for _, f := range structFields(t) {
+		if f.method != "" {
+			if err := c.checkMethod(t, f.method); err != nil {
+				return fmt.Errorf("field %s of %v: %v", f.name, t, err)
+			}
+			continue
+		}
+		if err := c.checkList(t.FieldByIndex(f.index).Type); err != nil {
+			return fmt.Errorf("field %s of %v: %v", f.name, t, err)
+		}
+	}
+	return nil
+}
+
+func (c *typeChecker) checkMethod(t reflect.Type, name string) error {
+	m, ok := reflect.PtrTo(t).MethodByName(name)
+	if !ok {
+		return fmt.Errorf("method %s of %v not found", name, t)
+	}
+	// the receiver is the first argument of a method from a type
+	mt := m.Type
+	if mt.NumIn() != 1 || mt.NumOut() != 1 {
+		return fmt.Errorf("method %s of %v must take no arguments and return a single value", name, t)
+	}
+	return c.checkList(mt.Out(0))
+}
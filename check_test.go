@@ -0,0 +1,33 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckType(t *testing.T) {
+	type node struct {
+		Value string
+		Next  *node
+	}
+	for i, v := range []interface{}{
+		1,
+		"a",
+		[]*string{},
+		Derived{},
+		node{},
+	} {
+		if err := CheckType(reflect.TypeOf(v)); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+	}
+	for i, v := range []interface{}{
+		make(chan int),
+		struct{ C chan int }{},
+		[]struct{ C []chan int }{},
+	} {
+		if err := CheckType(reflect.TypeOf(v)); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
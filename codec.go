@@ -0,0 +1,148 @@
+package teff
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"time"
+)
+
+// Codec holds the options of encoding and decoding, which are applied to the
+// Encoders and Decoders it creates. The zero value of a field means the default
+// of the corresponding option, except that an empty Indent means a tab, and a
+// nil FloatPrecision means the default precision.
+//
+// A Codec must not be modified once it is in use, then it is safe for
+// concurrent use by multiple goroutines, because each call creates its own
+// Encoder or Decoder. The functions in the options must also be safe for
+// concurrent use. Registry holds the enums, types, codecs, tuples and
+// positionals private to the Codec, consulted before the global registry.
+type Codec struct {
+	Registry *Registry
+
+	Prefix           string
+	Indent           string
+	GroupSpacing     bool
+	Inline           bool
+	AlignValues      bool
+	TrailingNewline  bool
+	DrainChannels    bool
+	StringerFallback bool
+	ErrorMessages    bool
+	SortFields       bool
+	Canonical        bool
+	EscapeNonASCII   bool
+	FoldWidth        int
+	FloatPrecision   *int
+	NumericBool      bool
+	UTC              bool
+	Header           string
+	NilToken         string
+	ByteEncoding     ByteEncoding
+	TrueTokens       []string
+	FalseTokens      []string
+	FieldNamer       func(string) string
+	SortMapByValue   func(a, b reflect.Value) bool
+	SelectFields     func(string) bool
+	JSONTags         bool
+	ElementAnnotator func(index int, elem interface{}) string
+
+	Strict              bool
+	Lenient             bool
+	CaseInsensitive     bool
+	Recover             bool
+	Merge               bool
+	ZeroBeforeDecode    bool
+	WrapScalars         bool
+	MaxStringLength     int
+	MaxIndent           int
+	UnknownEnumZero     bool
+	EnumCaseInsensitive bool
+	KeyValuePairs       bool
+	SkipRedacted        bool
+	Expander            func(string) string
+	DurationParser      func(string) (time.Duration, error)
+	Decompress          bool
+	Transform           func(io.Reader) io.Reader
+	Fallback            func([]byte, interface{}) error
+}
+
+func (c *Codec) NewEncoder(w io.Writer) *Encoder {
+	enc := NewEncoder(w)
+	indent := c.Indent
+	if indent == "" {
+		indent = "\t"
+	}
+	enc.SetIndent(c.Prefix, indent)
+	enc.SetRegistry(c.Registry)
+	enc.SetGroupSpacing(c.GroupSpacing)
+	enc.SetInline(c.Inline)
+	enc.SetAlignValues(c.AlignValues)
+	enc.SetTrailingNewline(c.TrailingNewline)
+	enc.SetDrainChannels(c.DrainChannels)
+	enc.SetStringerFallback(c.StringerFallback)
+	enc.SetErrorMessages(c.ErrorMessages)
+	enc.SetSortFields(c.SortFields)
+	enc.SetCanonical(c.Canonical)
+	enc.SetEscapeNonASCII(c.EscapeNonASCII)
+	enc.SetFoldWidth(c.FoldWidth)
+	if c.FloatPrecision != nil {
+		enc.SetFloatPrecision(*c.FloatPrecision)
+	}
+	enc.SetNumericBool(c.NumericBool)
+	enc.SetUTC(c.UTC)
+	enc.SetHeader(c.Header)
+	enc.SetNilToken(c.NilToken)
+	enc.SetByteEncoding(c.ByteEncoding)
+	enc.SetBoolTokens(c.TrueTokens, c.FalseTokens)
+	enc.SetFieldNamer(c.FieldNamer)
+	enc.SortMapByValue(c.SortMapByValue)
+	enc.SelectFields(c.SelectFields)
+	enc.SetJSONTags(c.JSONTags)
+	enc.SetElementAnnotator(c.ElementAnnotator)
+	return enc
+}
+
+func (c *Codec) NewDecoder(r io.Reader) *Decoder {
+	dec := NewDecoder(r)
+	dec.SetRegistry(c.Registry)
+	dec.SetStrict(c.Strict)
+	dec.SetLenient(c.Lenient)
+	dec.SetCaseInsensitive(c.CaseInsensitive)
+	dec.SetRecover(c.Recover)
+	dec.SetMerge(c.Merge)
+	dec.SetZeroBeforeDecode(c.ZeroBeforeDecode)
+	dec.SetWrapScalars(c.WrapScalars)
+	dec.SetMaxStringLength(c.MaxStringLength)
+	dec.SetMaxIndent(c.MaxIndent)
+	dec.SetUnknownEnumZero(c.UnknownEnumZero)
+	dec.SetEnumCaseInsensitive(c.EnumCaseInsensitive)
+	dec.SetNilToken(c.NilToken)
+	dec.SetByteEncoding(c.ByteEncoding)
+	dec.SetBoolTokens(c.TrueTokens, c.FalseTokens)
+	dec.SetKeyValuePairs(c.KeyValuePairs)
+	dec.SetSkipRedacted(c.SkipRedacted)
+	dec.SetFieldNamer(c.FieldNamer)
+	dec.SetJSONTags(c.JSONTags)
+	dec.SetExpander(c.Expander)
+	dec.SetDurationParser(c.DurationParser)
+	dec.SetDecompress(c.Decompress)
+	dec.SetTransform(c.Transform)
+	dec.SetFallback(c.Fallback)
+	return dec
+}
+
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	var w bytes.Buffer
+	if err := c.NewEncoder(&w).Encode(v); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// Unmarshal is like the package function Unmarshal but with the options of c.
+// In recovery mode, the errors of the skipped lines are discarded, use a
+// Decoder from NewDecoder to get them.
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return c.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
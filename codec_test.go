@@ -0,0 +1,141 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	type server struct {
+		HostName string
+		Port     int
+		Tags     []string
+	}
+	c := &Codec{
+		Indent:          "  ",
+		Inline:          true,
+		TrailingNewline: true,
+		FieldNamer:      strings.ToLower,
+		Strict:          true,
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := server{HostName: fmt.Sprintf("h%d", i), Port: i, Tags: []string{"a"}}
+			text := fmt.Sprintf("hostname: h%d\nport: %d\ntags:\n  a\n", i, i)
+			buf, err := c.Marshal(s)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(buf) != text {
+				errs <- fmt.Errorf("expect \n%s\n    but got \n%s", text, string(buf))
+				return
+			}
+			var decoded server
+			if err := c.Unmarshal(buf, &decoded); err != nil {
+				errs <- err
+				return
+			}
+			if !reflect.DeepEqual(decoded, s) {
+				errs <- fmt.Errorf("expect %#v but got %#v", s, decoded)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	var port int
+	if err := c.Unmarshal([]byte(`"1"`), &port); err == nil {
+		t.Fatal("expect error for a quoted int in strict mode")
+	}
+	selected := *c
+	selected.SelectFields = func(name string) bool { return name != "tags" }
+	buf, err := selected.Marshal(server{HostName: "h", Port: 1, Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := "hostname: h\nport: 1\n"; string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+}
+
+func TestNilToken(t *testing.T) {
+	type value struct {
+		P *int
+		M map[string]int
+		S string
+	}
+	c := &Codec{Inline: true, NilToken: "null"}
+	v := value{S: "null"}
+	buf, err := c.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := "P: null\nM: null\nS: \"null\""; string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	decoded := value{P: new(int), M: map[string]int{"a": 1}}
+	if err := c.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Fatalf("expect %#v but got %#v", v, decoded)
+	}
+	if err := c.Unmarshal([]byte("P: nil"), &decoded); err != nil || decoded.P != nil {
+		t.Fatalf("expect nil still accepted but got %v, %v", decoded.P, err)
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	type level int
+	type pair struct {
+		A, B int
+	}
+	type value struct {
+		L level
+		P pair
+		S Shape
+	}
+	r := NewRegistry()
+	r.RegisterEnum(reflect.TypeOf(level(0)), map[int64]string{1: "high"})
+	r.RegisterTuple(reflect.TypeOf(pair{}))
+	r.RegisterType("Square", reflect.TypeOf(rect{}))
+	c := &Codec{Registry: r, Inline: true}
+	v := value{1, pair{2, 3}, &rect{1, 1}}
+	text := "L: high\nP: 2 3\nS:\n\tSquare\n\t\tWidth: 1\n\t\tHeight: 1"
+	buf, err := c.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	var decoded value
+	if err := c.Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Fatalf("expect %#v but got %#v", v, decoded)
+	}
+	// the global registry is consulted after r, but does not see r.
+	text = "L: 1\nP:\n\tA: 2\n\tB: 3\nS:\n\tRect\n\t\tWidth: 1\n\t\tHeight: 1"
+	buf, err = (&Codec{Inline: true}).Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	if err := Unmarshal([]byte("S:\n\tSquare"), &decoded); err == nil {
+		t.Fatal("expect error for a type name registered only in the Codec")
+	}
+}
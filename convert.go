@@ -0,0 +1,181 @@
+package teff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"h12.io/teff/core"
+)
+
+// ToJSON converts a TEFF document to JSON. A list of key-value pairs becomes an
+// object, a single value becomes a scalar and any other list becomes an array.
+// The conversion is lossy: annotations are dropped, references are not
+// supported, and a list of one element cannot be told from a single value.
+func ToJSON(teffData []byte) ([]byte, error) {
+	list, err := core.Parse(bytes.NewReader(teffData))
+	if err != nil {
+		return nil, err
+	}
+	v, err := listToJSON(list)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func listToJSON(list core.List) (interface{}, error) {
+	if isObject(list) {
+		m := make(map[string]interface{}, len(list))
+		for _, node := range list {
+			k, value, _ := splitKeyValue(node)
+			key, err := NewDecoder(nil).parseString(k)
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = listToJSON(value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	if len(list) == 1 && list[0].Value != "_" {
+		return nodeToJSON(list[0])
+	}
+	a := make([]interface{}, len(list))
+	for i, node := range list {
+		var err error
+		if a[i], err = nodeToJSON(node); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func nodeToJSON(node core.Node) (interface{}, error) {
+	if node.IsReference {
+		return nil, fmt.Errorf("reference ^%s cannot be converted to JSON", node.Value)
+	}
+	if s, ok := blockString(node); ok {
+		return s, nil
+	}
+	if node.Value == "_" {
+		if isObject(node.List) {
+			return listToJSON(node.List)
+		}
+		a := make([]interface{}, len(node.List))
+		for i, elem := range node.List {
+			var err error
+			if a[i], err = nodeToJSON(elem); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	if len(node.List) > 0 {
+		return nil, fmt.Errorf("unexpected children of %s", node.Value)
+	}
+	switch node.Value {
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if _, err := strconv.ParseFloat(node.Value, 64); err == nil && json.Valid([]byte(node.Value)) {
+		return json.Number(node.Value), nil
+	}
+	return NewDecoder(nil).parseString(node.Value)
+}
+
+func isObject(list core.List) bool {
+	for _, node := range list {
+		if _, _, ok := splitKeyValue(node); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// FromJSON converts a JSON document to TEFF. An object becomes a list of
+// key-value pairs sorted by keys, and an array becomes a list of elements.
+// The conversion is lossy: an empty object becomes an empty list, which is
+// converted back to an empty array.
+func FromJSON(jsonData []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	list, err := enc.jsonToList(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.writeList(list); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func (enc *Encoder) jsonToList(v interface{}) (core.List, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		list := make(core.List, 0, len(keys))
+		for _, k := range keys {
+			key, err := enc.marshalKey(reflect.ValueOf(k))
+			if err != nil {
+				return nil, err
+			}
+			value, err := enc.jsonToList(v[k])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, core.Node{Value: key + ":", List: value})
+		}
+		return list, nil
+	case []interface{}:
+		list := make(core.List, len(v))
+		for i, elem := range v {
+			var err error
+			if list[i], err = enc.jsonToNode(elem); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	node, err := enc.jsonToNode(v)
+	if err != nil {
+		return nil, err
+	}
+	return core.List{node}, nil
+}
+
+func (enc *Encoder) jsonToNode(v interface{}) (core.Node, error) {
+	switch v := v.(type) {
+	case nil:
+		return enc.nilNode(), nil
+	case bool:
+		return core.Node{Value: strconv.FormatBool(v)}, nil
+	case json.Number:
+		return core.Node{Value: v.String()}, nil
+	case string:
+		return enc.marshalNode(reflect.ValueOf(v))
+	}
+	list, err := enc.jsonToList(v)
+	if err != nil {
+		return core.Node{}, err
+	}
+	return core.Node{Value: "_", List: list}, nil
+}
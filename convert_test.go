@@ -0,0 +1,46 @@
+package teff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	for i, testcase := range []struct {
+		json string
+		teff string
+	}{
+		{`null`, "nil"},
+		{`"a"`, "a"},
+		{`"1"`, `"1"`},
+		{`1.5`, "1.5"},
+		{`[1,true,null]`, "1\ntrue\nnil"},
+		{`[]`, ""},
+		{`{"a":1,"b c":["x","y"]}`, "a:\n\t1\nb c:\n\tx\n\ty"},
+		{`[{"a":1},[2,3]]`, "_\n\ta:\n\t\t1\n_\n\t2\n\t3"},
+		{`{"a":{"b":"line1\nline2"}}`, "a:\n\tb:\n\t\t|\n\t\t\tline1\n\t\t\tline2"},
+	} {
+		teffData, err := FromJSON([]byte(testcase.json))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(teffData) != testcase.teff {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.teff, teffData)
+		}
+		jsonData, err := ToJSON(teffData)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var expected bytes.Buffer
+		if err := json.Compact(&expected, []byte(testcase.json)); err != nil {
+			t.Fatal(err)
+		}
+		if string(jsonData) != expected.String() {
+			t.Fatalf("testcase %d: expect %s but got %s", i, expected.String(), jsonData)
+		}
+	}
+	if _, err := ToJSON([]byte("# ^1\na\n^1")); err == nil {
+		t.Fatal("expect error for a reference")
+	}
+}
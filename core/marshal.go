@@ -14,21 +14,35 @@ func (list List) String() string {
 
 func (list List) Marshal(w io.Writer, prefix, indent string) error {
 	ew := newErrWriter(w)
-	list.marshal(&ew, "", "\t")
+	list.marshal(&ew, prefix, indent, nil)
 	ew.flush()
 	return ew.err
 }
 
-func (list List) marshal(w *errWriter, prefix, indent string) {
+// MarshalBlankLines is like Marshal but also writes the number of blank lines
+// in blanks before each node, as returned by ParseBlankLines. The blank lines
+// of a node are lost if its list has been appended to or reallocated since
+// parsing, because the node is then at another address.
+func (list List) MarshalBlankLines(w io.Writer, prefix, indent string, blanks map[*Node]int) error {
+	ew := newErrWriter(w)
+	list.marshal(&ew, prefix, indent, blanks)
+	ew.flush()
+	return ew.err
+}
+
+func (list List) marshal(w *errWriter, prefix, indent string, blanks map[*Node]int) {
 	for i := range list {
 		if i > 0 {
 			w.writeByte('\n')
 		}
-		list[i].marshal(w, prefix, indent)
+		for j := 0; j < blanks[&list[i]]; j++ {
+			w.writeByte('\n')
+		}
+		list[i].marshal(w, prefix, indent, blanks)
 	}
 }
 
-func (n *Node) marshal(w *errWriter, prefix, indent string) {
+func (n *Node) marshal(w *errWriter, prefix, indent string, blanks map[*Node]int) {
 	for _, a := range n.Annotations {
 		w.writeString(prefix)
 		w.writeByte('#')
@@ -44,7 +58,7 @@ func (n *Node) marshal(w *errWriter, prefix, indent string) {
 	}
 	if len(n.List) > 0 {
 		w.writeByte('\n')
-		n.List.marshal(w, prefix+indent, indent)
+		n.List.marshal(w, prefix+indent, indent, blanks)
 	}
 }
 
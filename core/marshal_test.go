@@ -16,3 +16,15 @@ func TestMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestMarshalIndent(t *testing.T) {
+	list := List{{Value: "a:", List: List{{Value: "b:", List: List{{Value: "1"}}}}}}
+	var w strings.Builder
+	if err := list.Marshal(&w, "> ", "  "); err != nil {
+		t.Fatal(err)
+	}
+	expected := "> a:\n>   b:\n>     1"
+	if w.String() != expected {
+		t.Fatalf("expect \n%s\nbut got \n%s", expected, w.String())
+	}
+}
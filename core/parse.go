@@ -12,11 +12,80 @@ var (
 )
 
 func Parse(reader io.Reader) (List, error) {
-	s := newParseStack()
+	list, _, err := parse(NewScanner(bufio.NewReader(reader)), false, nil, nil)
+	return list, err
+}
+
+// ParseScanner parses the tokens from scanner, e.g. a scanner with a filter.
+func ParseScanner(scanner *Scanner) (List, error) {
+	list, _, err := parse(scanner, false, nil, nil)
+	return list, err
+}
+
+// ParseRecover is like Parse but skips the lines with an invalid code point or
+// a mismatched indent, and returns the errors of the skipped lines.
+func ParseRecover(reader io.Reader) (List, []error, error) {
 	scanner := NewScanner(bufio.NewReader(reader))
+	scanner.SetRecover(true)
+	list, _, err := parse(scanner, false, nil, nil)
+	return list, scanner.Errors(), err
+}
+
+// ParseFirst parses only the first top-level node and returns the byte offset
+// where the next top-level node starts, or the length of the input if there is
+// no more node.
+func ParseFirst(reader io.Reader) (List, int, error) {
+	return parse(NewScanner(bufio.NewReader(reader)), true, nil, nil)
+}
+
+// ParseEach parses the tokens from scanner and calls fn with each top-level
+// node as soon as it is complete, so that only one top-level node is held in
+// memory at a time. It stops at the first error returned by fn.
+func ParseEach(scanner *Scanner, fn func(Node) error) error {
+	_, _, err := parse(scanner, false, fn, nil)
+	return err
+}
+
+// ParseBlankLines is like ParseScanner but also returns the number of blank
+// lines before each node that has any, as recorded by a scanner with
+// SetKeepBlankLines, to be written back by List.MarshalBlankLines. The nodes
+// are keyed by their addresses in the returned list, so the keys become
+// invalid if a list is appended to or reallocated, e.g. when transforming the
+// list before marshalling it. Nodes can still be modified in place.
+func ParseBlankLines(scanner *Scanner) (List, map[*Node]int, error) {
+	var blanks blankLines
+	list, _, err := parse(scanner, false, nil, &blanks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, blanks.resolve(list), nil
+}
+
+func parse(scanner *Scanner, first bool, each func(Node) error, blankLines *blankLines) (List, int, error) {
+	s := newParseStack()
 	var a []string
+	blanks := 0
+	offset := 0
 	for scanner.Scan() {
 		tok := scanner.Token()
+		if (first || each != nil) && len(s.s) == 1 && len(*s.top()) == 1 && len(a) == 0 {
+			switch tok.Type {
+			case LineValue, Reference, Annotation:
+				if first {
+					return *s.top(), tok.Offset, nil
+				}
+				if err := each((*s.top())[0]); err != nil {
+					return nil, 0, err
+				}
+				*s.top() = (*s.top())[:0]
+			}
+		}
+		if len(a) == 0 {
+			blanks = tok.BlankLines
+		}
+		if blankLines != nil && blanks > 0 && (tok.Type == LineValue || tok.Type == Reference) {
+			blankLines.add(&s, blanks)
+		}
 		switch tok.Type {
 		case LineValue:
 			s.top().add(Node{Value: tok.Content, Annotations: a})
@@ -28,24 +97,63 @@ func Parse(reader io.Reader) (List, error) {
 			a = append(a, tok.Content)
 		case Indent:
 			if len(a) > 0 {
-				return nil, errAnnotationWithoutNode
+				return nil, 0, errAnnotationWithoutNode
 			}
 			last := s.top().last()
 			if last == nil {
-				return nil, errWrongIndent
+				return nil, 0, errWrongIndent
 			}
 			s.push(&last.List)
 		case Unindent:
 			if len(a) > 0 {
-				return nil, errAnnotationWithoutNode
+				return nil, 0, errAnnotationWithoutNode
 			}
 			s.pop()
+		case EOF:
+			offset = tok.Offset
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if each != nil && len(*s.top()) > 0 {
+		return nil, 0, each((*s.top())[0])
+	}
+	return *s.top(), offset, nil
+}
+
+// blankLines records the blank lines before the nodes by their index paths
+// while parsing, because the addresses of the nodes change as the lists grow.
+type blankLines []struct {
+	path []int
+	n    int
+}
+
+// add records n blank lines before the node about to be added to the top of s.
+func (b *blankLines) add(s *parseStack, n int) {
+	path := make([]int, len(s.s))
+	for i, l := range s.s {
+		path[i] = len(*l) - 1
+	}
+	path[len(path)-1]++
+	*b = append(*b, struct {
+		path []int
+		n    int
+	}{path, n})
+}
+
+func (b blankLines) resolve(list List) map[*Node]int {
+	m := make(map[*Node]int, len(b))
+	for _, blank := range b {
+		l := list
+		var node *Node
+		for _, i := range blank.path {
+			node = &l[i]
+			l = node.List
+		}
+		m[node] = blank.n
 	}
-	return *s.top(), nil
+	return m
 }
 
 type parseStack struct {
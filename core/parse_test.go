@@ -1,6 +1,8 @@
 package core
 
 import (
+	"bufio"
+	"bytes"
 	"reflect"
 	"strings"
 	"testing"
@@ -44,3 +46,133 @@ a
 		}
 	}
 }
+
+func TestParseFirst(t *testing.T) {
+	for i, testcase := range []struct {
+		s      string
+		list   List
+		offset int
+	}{
+		{"", List{}, 0},
+		{"a", List{{Value: "a"}}, 1},
+		{"a\nb", List{{Value: "a"}}, 2},
+		{"a\n\tb\n\n#c\nc", List{{Value: "a", List: List{{Value: "b"}}}}, 6},
+		{"#a\na\n^b", List{{Value: "a", Annotations: []string{"a"}}}, 5},
+		{"\ufeffa\nb", List{{Value: "a"}}, 5},
+	} {
+		list, offset, err := ParseFirst(strings.NewReader(testcase.s))
+		if err != nil {
+			t.Fatalf("testcase %d, %v", i, err)
+		}
+		if !reflect.DeepEqual(list, testcase.list) {
+			t.Fatalf("testcase %d: expect \n%#v\nbut got \n%#v", i, testcase.list, list)
+		}
+		if offset != testcase.offset {
+			t.Fatalf("testcase %d: expect offset %d but got %d", i, testcase.offset, offset)
+		}
+	}
+}
+
+func TestParseEach(t *testing.T) {
+	for i, testcase := range []struct {
+		s    string
+		list List
+	}{
+		{"", nil},
+		{"a", List{{Value: "a"}}},
+		{"a\n\tb\n\n#c\nc\n^d", List{{Value: "a", List: List{{Value: "b"}}}, {Value: "c", Annotations: []string{"c"}}, {Value: "d", IsReference: true}}},
+	} {
+		var list List
+		err := ParseEach(NewScanner(bufio.NewReader(strings.NewReader(testcase.s))), func(node Node) error {
+			list = append(list, node)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("testcase %d, %v", i, err)
+		}
+		if !reflect.DeepEqual(list, testcase.list) {
+			t.Fatalf("testcase %d: expect \n%#v\nbut got \n%#v", i, testcase.list, list)
+		}
+	}
+}
+
+func TestParseRecover(t *testing.T) {
+	list, errs, err := ParseRecover(strings.NewReader("a\nb\x00c\n\td\n  x\n\x01\ne"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{
+		{Value: "a", List: List{{Value: "d"}}},
+		{Value: "e"},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Fatalf("expect \n%#v\nbut got \n%#v", expected, list)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expect 3 errors but got %v", errs)
+	}
+	if _, err := Parse(strings.NewReader("a\nb\x00c\nd")); err == nil {
+		t.Fatal("expect error without recovery")
+	}
+}
+
+func TestParseScannerFilter(t *testing.T) {
+	scanner := NewScanner(bufio.NewReader(strings.NewReader("#a\nx\n\t#b\n\ty\n#c\nz")))
+	scanner.SetFilter(func(tok Token) (Token, bool) {
+		switch tok.Type {
+		case Annotation:
+			return tok, false
+		case LineValue:
+			tok.Content = strings.ToUpper(tok.Content)
+		}
+		return tok, true
+	})
+	list, err := ParseScanner(scanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := List{{Value: "X", List: List{{Value: "Y"}}}, {Value: "Z"}}
+	if !reflect.DeepEqual(list, expected) {
+		t.Fatalf("expect \n%#v\nbut got \n%#v", expected, list)
+	}
+}
+
+func TestKeepBlankLines(t *testing.T) {
+	for i, testcase := range []struct {
+		text     string
+		expected string
+	}{
+		{"a:\n\t1\n\nb:\n\t2\n\n\n# c\nc:\n\n\t3", ""},
+		{"a\n\t|\n\t\tx\n\n\t\ty\n\n\tb\nc", ""},
+		{"\r\n\r\na\r\n\r\nb", "\n\na\n\nb"},
+		{"a\n  \n\t\n\tb\n\n", "a\n\n\n\tb"},
+	} {
+		scanner := NewScanner(bufio.NewReader(strings.NewReader(testcase.text)))
+		scanner.SetKeepBlankLines(true)
+		list, blanks, err := ParseBlankLines(scanner)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		expected := testcase.expected
+		if expected == "" {
+			expected = testcase.text
+		}
+		var w bytes.Buffer
+		if err := list.MarshalBlankLines(&w, "", "\t", blanks); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s := w.String(); s != expected {
+			t.Fatalf("testcase %d: expect \n%q\nbut got \n%q", i, expected, s)
+		}
+	}
+	list, blanks, err := ParseBlankLines(NewScanner(bufio.NewReader(strings.NewReader("a\n\n\nb"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blanks) != 0 {
+		t.Fatalf("expect blank lines to be ignored by default but got %v", blanks)
+	}
+	if s := list.String(); s != "a\nb" {
+		t.Fatalf("expect blank lines to be ignored by default but got %q", s)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"unicode"
@@ -10,6 +11,7 @@ import (
 var (
 	errInvalidCodePoint = errors.New("invalid code point")
 	errMismatchIndent   = errors.New("mismatch indent")
+	errIndentTooLong    = errors.New("indent too long")
 )
 
 type TokenType int
@@ -26,20 +28,30 @@ const (
 )
 
 type Token struct {
-	Type    TokenType
-	Content string
+	Type       TokenType
+	Content    string
+	Offset     int // byte offset of the line after the indent
+	End        int // byte offset of the end of the line before the line break
+	BlankLines int // number of blank lines before the line, see SetKeepBlankLines
 }
 
 type Scanner struct {
 	reader
 	indenter
 	tokenQueue
-	err error
+	err     error
+	recover bool
+	errs    []error
+	started bool
+	filter  func(Token) (Token, bool)
+	keep    bool
+	blanks  int
+	lines   int
 }
 
 func NewScanner(r io.RuneScanner) *Scanner {
 	return &Scanner{
-		reader: reader{r: r},
+		reader: reader{r: r, spaces: defaultIndentSpaces},
 		indenter: indenter{
 			indents: []string{""},
 		},
@@ -49,50 +61,216 @@ func NewScanner(r io.RuneScanner) *Scanner {
 	}
 }
 
+// SetIndentSpaces sets the whitespace characters recognized as indentation,
+// the default is space and tab.
+func (s *Scanner) SetIndentSpaces(spaces string) {
+	s.spaces = spaces
+}
+
+// SetMaxIndent sets the maximum number of characters of an indent, so that a
+// pathologically long indent fails early. Zero, the default, means no limit.
+func (s *Scanner) SetMaxIndent(n int) {
+	s.maxIndent = n
+}
+
+// SetFilter sets a function transforming each token before it is returned by
+// Scan or Peek, the token is dropped if the function returns false. It allows
+// preprocessing such as stripping annotations or renaming values before
+// parsing with ParseScanner.
+func (s *Scanner) SetFilter(filter func(Token) (Token, bool)) {
+	s.filter = filter
+}
+
+// SetKeepBlankLines sets whether the number of blank lines before each line of
+// an annotation, a reference or a value is recorded in its token, so that the
+// blank lines can be kept with ParseBlankLines and List.MarshalBlankLines. By
+// default, blank lines are ignored.
+func (s *Scanner) SetKeepBlankLines(keep bool) {
+	s.keep = keep
+}
+
+// SetRecover sets whether a line with an invalid code point or a mismatched
+// indent is skipped rather than stopping the scanning. The errors of the
+// skipped lines are returned by Errors.
+func (s *Scanner) SetRecover(recover bool) {
+	s.recover = recover
+}
+
+// Errors returns the errors of the lines skipped in recovery mode.
+func (s *Scanner) Errors() []error {
+	return s.errs
+}
+
 func (s *Scanner) Scan() bool {
 	s.popTok()
-	if s.tokCount() > 0 {
-		return true
-	}
-	if s.err != nil {
-		return false
+	s.fill(1)
+	return s.tokCount() > 0
+}
+
+// Peek returns the token after the current one without advancing the scanner.
+// It returns false if there is no more token.
+func (s *Scanner) Peek() (Token, bool) {
+	s.fill(2)
+	if s.tokCount() < 2 {
+		return Token{}, false
 	}
-	s.scanLine()
-	if s.err == io.EOF {
-		for i := 0; i < s.eofUnindentLevel(); i++ {
-			s.pushTok(Token{Type: Unindent})
+	return s.toks[1], true
+}
+
+// fill scans lines until there are at least n tokens in the queue or an error
+// occurs.
+func (s *Scanner) fill(n int) {
+	for s.tokCount() < n && s.err == nil {
+		s.scanLine()
+		if s.err == io.EOF {
+			n := s.eofUnindentLevel()
+			for i := 0; i < n; i++ {
+				s.pushTok(Token{Type: Unindent})
+			}
+			s.pushTok(Token{Type: EOF, Offset: s.offset, End: s.offset})
 		}
-		s.pushTok(Token{Type: EOF})
 	}
-	return s.tokCount() > 0
 }
 
 func (s *Scanner) scanLine() {
+	if !s.started {
+		s.started = true
+		s.skipBOM()
+	}
 	var indent string
+	s.breaks = 0
 	indent, s.err = s.readValidIndent()
+	s.blanks = s.breaks
+	if s.lines > 0 && s.blanks > 0 {
+		s.blanks--
+	}
 	if s.err != nil {
+		s.recoverLine()
 		return
 	}
+	offset := s.offset
+	var line string
+	line, s.err = s.readLine()
+	if s.err != nil && s.recoverLine() {
+		return
+	}
+	s.scanContent(indent, line, offset)
+}
+
+func (s *Scanner) scanContent(indent, line string, offset int) {
 	indentType, n, err := s.indentLevel(indent)
 	if err != nil {
 		s.err = err
+		s.recoverLine()
 		return
 	}
 	for i := 0; i < n; i++ {
 		s.pushTok(Token{Type: indentType})
 	}
-	var line string
-	line, s.err = s.readLine()
+	end := offset + len(line)
+	blanks := 0
+	if s.keep {
+		blanks = s.blanks
+	}
+	s.lines++
 	switch line[0] {
 	case '#':
-		s.pushTok(Token{Type: Annotation, Content: line[1:]})
+		s.pushTok(Token{Type: Annotation, Content: line[1:], Offset: offset, End: end, BlankLines: blanks})
 	case '^':
-		s.pushTok(Token{Type: Reference, Content: line[1:]})
+		s.pushTok(Token{Type: Reference, Content: line[1:], Offset: offset, End: end, BlankLines: blanks})
 	default:
-		s.pushTok(Token{Type: LineValue, Content: line})
+		s.pushTok(Token{Type: LineValue, Content: line, Offset: offset, End: end, BlankLines: blanks})
+		if (line == BlockMarker || line == FoldMarker) && s.err == nil {
+			s.scanBlock()
+		}
 	}
 }
 
+// BlockMarker is the value of a line followed by a block string.
+const BlockMarker = "|"
+
+// FoldMarker is the value of a line followed by a folded block string, whose
+// lines are joined by spaces rather than line breaks.
+const FoldMarker = ">"
+
+// scanBlock scans the block string following a line of BlockMarker or
+// FoldMarker. The block consists of the lines indented deeper than the marker
+// line, and its base indent is the indent of its first non-blank line. The
+// block ends before the first non-blank line whose indent does not start with
+// the base indent, which is then scanned as usual.
+//
+// Each line of the block is emitted verbatim as a LineValue after stripping the
+// base indent, so deeper indents and leading '#' or '^' are kept as content. A
+// blank line shorter than the base indent is emitted as an empty LineValue,
+// except at the beginning or the end of the block where it is dropped. The
+// lines are enclosed by Indent and Unindent, which do not affect the indent
+// stack.
+func (s *Scanner) scanBlock() {
+	parent := s.indents[len(s.indents)-1]
+	var base string
+	var toks []Token
+	blanks := 0
+	for s.skipLineBreak() {
+		start := s.offset
+		indent := s.indentSpaces()
+		offset := s.offset
+		var line string
+		if s.err = s.reader.err; s.err == nil {
+			line, s.err = s.readLine()
+		}
+		if s.err != nil && s.err != io.EOF {
+			return
+		}
+		if base == "" && line != "" && len(indent) > len(parent) && strings.HasPrefix(indent, parent) {
+			base = indent
+		}
+		if base == "" || !strings.HasPrefix(indent, base) {
+			if line == "" {
+				if base != "" {
+					blanks++
+				}
+				continue
+			}
+			s.pushBlock(toks)
+			s.blanks = blanks
+			s.scanContent(indent, line, offset)
+			return
+		}
+		for ; blanks > 0; blanks-- {
+			toks = append(toks, Token{Type: LineValue})
+		}
+		content := indent[len(base):] + line
+		toks = append(toks, Token{Type: LineValue, Content: content, Offset: start + len(base), End: start + len(base) + len(content)})
+	}
+	if s.err = s.reader.err; s.err != nil && s.err != io.EOF {
+		return
+	}
+	s.pushBlock(toks)
+}
+
+func (s *Scanner) pushBlock(toks []Token) {
+	if len(toks) == 0 {
+		return
+	}
+	s.pushTok(Token{Type: Indent})
+	for _, tok := range toks {
+		s.pushTok(tok)
+	}
+	s.pushTok(Token{Type: Unindent})
+}
+
+// recoverLine skips the rest of the current line and clears the error if the
+// error is recoverable in recovery mode.
+func (s *Scanner) recoverLine() bool {
+	if !s.recover || s.err != errInvalidCodePoint && s.err != errMismatchIndent {
+		return false
+	}
+	s.errs = append(s.errs, fmt.Errorf("offset %d: %v", s.offset, s.err))
+	s.skipLine()
+	s.err = s.reader.err
+	return true
+}
+
 func (s *Scanner) Err() error {
 	if s.err == io.EOF {
 		return nil
@@ -100,10 +278,17 @@ func (s *Scanner) Err() error {
 	return s.err
 }
 
+const defaultIndentSpaces = " \t"
+
 type reader struct {
-	r   io.RuneScanner
-	ch  rune
-	err error
+	r         io.RuneScanner
+	ch        rune
+	size      int
+	offset    int
+	err       error
+	spaces    string
+	maxIndent int
+	breaks    int
 }
 
 func (s *reader) readLine() (string, error) {
@@ -119,6 +304,22 @@ func (s *reader) readLine() (string, error) {
 	return string(rs), s.err
 }
 
+// skipLine skips the rest of a line regardless of invalid code points.
+func (s *reader) skipLine() {
+	for {
+		s.ch, s.size, s.err = s.r.ReadRune()
+		if s.err != nil {
+			return
+		}
+		s.offset += s.size
+		switch s.ch {
+		case '\r', '\n':
+			s.prev()
+			return
+		}
+	}
+}
+
 // readValidIndent reads an indent that not ends with line breaks, and skips
 // a line when necessary.
 func (s *reader) readValidIndent() (string, error) {
@@ -127,15 +328,51 @@ func (s *reader) readValidIndent() (string, error) {
 		if s.err != nil {
 			return indent, s.err
 		}
-		if !s.skipLineBreaks() {
+		if !s.skipLineBreaks() || s.err != nil {
 			return indent, s.err
 		}
 	}
 }
+
+// skipBOM skips a UTF-8 byte order mark at the start of the input.
+func (s *reader) skipBOM() {
+	ch, size, err := s.r.ReadRune()
+	if err != nil {
+		return
+	}
+	if ch != '\uFEFF' {
+		s.r.UnreadRune()
+		return
+	}
+	s.offset += size
+}
+
+// skipLineBreak skips a single line break, it returns false at the end of the
+// input or on error.
+func (s *reader) skipLineBreak() bool {
+	if !s.next() {
+		return false
+	}
+	if s.ch == '\r' {
+		if s.next() && s.ch != '\n' {
+			s.prev()
+		}
+		return s.err == nil || s.err == io.EOF
+	}
+	return s.ch == '\n'
+}
+
+// skipLineBreaks skips consecutive line breaks and counts them in breaks, where
+// "\r\n" counts as one.
 func (s *reader) skipLineBreaks() (isLineBreak bool) {
+	cr := false
 	for s.next() {
 		switch s.ch {
 		case '\r', '\n':
+			if s.ch == '\r' || !cr {
+				s.breaks++
+			}
+			cr = s.ch == '\r'
 			isLineBreak = true
 		default:
 			s.prev()
@@ -147,29 +384,32 @@ func (s *reader) skipLineBreaks() (isLineBreak bool) {
 func (s *reader) indentSpaces() string {
 	rs := []rune{}
 	for s.next() {
-		switch s.ch {
-		case ' ', '\t':
-		default:
+		if !s.isIndentSpace(s.ch) {
 			s.prev()
 			return string(rs)
 		}
+		if s.maxIndent > 0 && len(rs) == s.maxIndent {
+			s.err = errIndentTooLong
+			return ""
+		}
 		rs = append(rs, s.ch)
 	}
 	return string(rs)
 }
 
 func (s *reader) next() bool {
-	s.ch, _, s.err = s.r.ReadRune()
+	s.ch, s.size, s.err = s.r.ReadRune()
 	if s.err != nil {
 		return false
 	}
+	s.offset += s.size
 	switch s.ch {
 	case '\t', ' ', '\r', '\n':
 	case unicode.ReplacementChar:
 		s.err = errInvalidCodePoint
 		return false
 	default:
-		if '\x00' <= s.ch && s.ch <= '\x19' {
+		if '\x00' <= s.ch && s.ch <= '\x19' && !s.isIndentSpace(s.ch) {
 			s.err = errInvalidCodePoint
 			return false
 		}
@@ -177,9 +417,17 @@ func (s *reader) next() bool {
 	return true
 }
 
+func (s *reader) isIndentSpace(ch rune) bool {
+	return strings.ContainsRune(s.spaces, ch)
+}
+
 func (s *reader) prev() bool {
 	s.err = s.r.UnreadRune()
-	return s.err == nil
+	if s.err != nil {
+		return false
+	}
+	s.offset -= s.size
+	return true
 }
 
 type indenter struct {
@@ -224,6 +472,16 @@ func (s *tokenQueue) pushTok(tok Token) {
 	s.toks = append(s.toks, tok)
 }
 
+func (s *Scanner) pushTok(tok Token) {
+	if s.filter != nil {
+		var ok bool
+		if tok, ok = s.filter(tok); !ok {
+			return
+		}
+	}
+	s.tokenQueue.pushTok(tok)
+}
+
 func (s *tokenQueue) popTok() {
 	s.toks = s.toks[1:]
 }
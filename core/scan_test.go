@@ -64,6 +64,7 @@ func TestScan(t *testing.T) {
 		{"x\n\ty\nz", "<x:s> <in> <y:s> <un> <z:s> <eof>"},
 		{"1\n\t2\n\t\t3\n\t\t\t4\n5", "<1:s> <in> <2:s> <in> <3:s> <in> <4:s> <un> <un> <un> <5:s> <eof>"},
 
+		{"x\n\ty\n\t\tz", "<x:s> <in> <y:s> <in> <z:s> <un> <un> <eof>"},
 		{"\tx\n\t\ty\nz", "<in> <x:s> <in> <y:s> <un> <un> <z:s> <eof>"},
 		{"\t#x\n#y\ny", "<in> <x:a> <un> <y:a> <y:s> <eof>"},
 	} {
@@ -78,6 +79,34 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestPeek(t *testing.T) {
+	s := NewScanner(bufio.NewReader(strings.NewReader("a\n\tb")))
+	var toks []string
+	for {
+		peeked, ok := s.Peek()
+		if again, _ := s.Peek(); again != peeked {
+			t.Fatalf("expect Peek not to advance but got %v after %v", again, peeked)
+		}
+		if !s.Scan() {
+			if ok {
+				t.Fatalf("expect no more token but peeked %v", peeked)
+			}
+			break
+		}
+		if !ok || peeked != s.Token() {
+			t.Fatalf("expect peeked %v but got %v", s.Token(), peeked)
+		}
+		toks = append(toks, s.Token().String())
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	expected := "<a:s> <in> <b:s> <un> <eof>"
+	if actual := strings.Join(toks, " "); actual != expected {
+		t.Fatalf("expect\n%s\ngot\n%s\n", expected, actual)
+	}
+}
+
 func TestMismatch(t *testing.T) {
 	_, err := scanAll("x\n\ty\n x")
 	if err == nil {
@@ -85,6 +114,35 @@ func TestMismatch(t *testing.T) {
 	}
 }
 
+func TestOddIndent(t *testing.T) {
+	for i, testcase := range []struct {
+		text     string
+		expected string
+	}{
+		{"x\n   y\n      z\n   w\nv", "<x:s> <in> <y:s> <in> <z:s> <un> <w:s> <un> <v:s> <eof>"},
+		{"x\n     y\n          z\nw", "<x:s> <in> <y:s> <in> <z:s> <un> <un> <w:s> <eof>"},
+		{"x\n   y\n      z\n         u\n   w", "<x:s> <in> <y:s> <in> <z:s> <in> <u:s> <un> <un> <w:s> <un> <eof>"},
+	} {
+		toks, err := scanAll(testcase.text)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := strings.Join(toks, " "); actual != testcase.expected {
+			t.Fatalf("testcase %d: expect\n%s\ngot\n%s\n", i, testcase.expected, actual)
+		}
+	}
+	for i, testcase := range []string{
+		"x\n   y\n      z\n    w",
+		"x\n   y\n      z\n w",
+		"x\n   y\n      z\n  w",
+		"x\n     y\n          z\n       w",
+	} {
+		if _, err := scanAll(testcase); err == nil {
+			t.Fatalf("testcase %d: expect mismatch error for a partial unindent", i)
+		}
+	}
+}
+
 func TestInvalidChar(t *testing.T) {
 	for i, testcase := range []string{
 		"\x00",
@@ -98,6 +156,25 @@ func TestInvalidChar(t *testing.T) {
 	}
 }
 
+func TestIndentSpaces(t *testing.T) {
+	s := NewScanner(bufio.NewReader(strings.NewReader("x\n\fy\n\f\vz")))
+	s.SetIndentSpaces(" \t\f\v")
+	var toks []string
+	for s.Scan() {
+		toks = append(toks, s.Token().String())
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	expected := "<x:s> <in> <y:s> <in> <z:s> <un> <un> <eof>"
+	if actual := strings.Join(toks, " "); actual != expected {
+		t.Fatalf("expect\n%s\ngot\n%s\n", expected, actual)
+	}
+	if _, err := scanAll("x\n\fy"); err == nil {
+		t.Fatal("expect error for form feed with default indent spaces.")
+	}
+}
+
 func TestReadError(t *testing.T) {
 	s := NewScanner(errRuneReader{})
 	if s.Scan() != false || s.Err() == nil {
@@ -121,3 +198,89 @@ func scanAll(testcase string) (toks []string, err error) {
 	}
 	return
 }
+
+func TestBlock(t *testing.T) {
+	for i, testcase := range []struct {
+		text     string
+		expected string
+	}{
+		{"|", "<|:s> <eof>"},
+		{"|\na", "<|:s> <a:s> <eof>"},
+		{"|\n\ta\n\t  b\n\n\t#c\nd", "<|:s> <in> <a:s> <  b:s> <s> <#c:s> <un> <d:s> <eof>"},
+		{"x\n\t|\n\t\ta\n\t\t\t b\n\n\ty", "<x:s> <in> <|:s> <in> <a:s> <\t b:s> <un> <y:s> <un> <eof>"},
+		{"|\n\t a\n\tb", "<|:s> <in> <a:s> <un> <in> <b:s> <un> <eof>"},
+		{"|\n\n\ta\n\t\n\n", "<|:s> <in> <a:s> <s> <un> <eof>"},
+		{"|\r\n\ta\r\n\t b\r\nc", "<|:s> <in> <a:s> < b:s> <un> <c:s> <eof>"},
+		{">\n\ta b\n\t#c\nd", "<>:s> <in> <a b:s> <#c:s> <un> <d:s> <eof>"},
+	} {
+		toks, err := scanAll(testcase.text)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := strings.Join(toks, " "); actual != testcase.expected {
+			t.Fatalf("testcase %d: expect\n%s\ngot\n%s\n", i, testcase.expected, actual)
+		}
+	}
+}
+
+func TestBOM(t *testing.T) {
+	for i, testcase := range []struct {
+		text     string
+		expected string
+	}{
+		{"\ufeff", "<eof>"},
+		{"\ufeffa\n\tb", "<a:s> <in> <b:s> <un> <eof>"},
+		{"\ufeff\ta", "<in> <a:s> <un> <eof>"},
+	} {
+		toks, err := scanAll(testcase.text)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if actual := strings.Join(toks, " "); actual != testcase.expected {
+			t.Fatalf("testcase %d: expect\n%s\ngot\n%s\n", i, testcase.expected, actual)
+		}
+	}
+	if toks, err := scanAll("a\n\ufeffb"); err == nil && strings.Join(toks, " ") == "<a:s> <b:s> <eof>" {
+		t.Fatal("expect a BOM not at the start to be kept")
+	}
+}
+
+func TestMaxIndent(t *testing.T) {
+	for i, testcase := range []struct {
+		text string
+		ok   bool
+	}{
+		{"a\n\t\tb", true},
+		{"a\n\t\t\tb", false},
+		{"|\n\t\t\ta", false},
+		{"a\n" + strings.Repeat(" ", 1<<20) + "b", false},
+	} {
+		s := NewScanner(bufio.NewReader(strings.NewReader(testcase.text)))
+		s.SetMaxIndent(2)
+		for s.Scan() {
+		}
+		if ok := s.Err() == nil; ok != testcase.ok {
+			t.Fatalf("testcase %d: expect ok %v but got error %v", i, testcase.ok, s.Err())
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	text := "# c\na\n\t#d\n\tb\n\t|\n\t\tx y\nz"
+	s := NewScanner(bufio.NewReader(strings.NewReader(text)))
+	var ranges []string
+	for s.Scan() {
+		tok := s.Token()
+		switch tok.Type {
+		case Annotation, LineValue:
+			ranges = append(ranges, text[tok.Offset:tok.End])
+		}
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	expected := "[# c] [a] [#d] [b] [|] [x y] [z]"
+	if actual := "[" + strings.Join(ranges, "] [") + "]"; actual != expected {
+		t.Fatalf("expect\n%s\ngot\n%s\n", expected, actual)
+	}
+}
@@ -0,0 +1,193 @@
+package teff
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+// Marshaler is implemented by a type that encodes itself into TEFF.
+type Marshaler interface {
+	MarshalTEFF() ([]byte, error)
+}
+
+// Unmarshaler is implemented by a type that decodes itself from TEFF. The
+// input is the TEFF text of the value, indented by tabs.
+type Unmarshaler interface {
+	UnmarshalTEFF([]byte) error
+}
+
+type codec struct {
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}
+
+// RegisterCodec registers the custom encoding of a type t that cannot
+// implement Marshaler and Unmarshaler, e.g. a type of another package. marshal
+// is called with a value of t and returns its TEFF text, and unmarshal is
+// called with the TEFF text and a pointer to a value of t.
+func RegisterCodec(t reflect.Type, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) {
+	globalRegistry.RegisterCodec(t, marshal, unmarshal)
+}
+
+// RegisterCodec is like the function RegisterCodec but registers into r.
+func (r *Registry) RegisterCodec(t reflect.Type, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error) {
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("teff: registering codec of pointer or interface type %v", t))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[t] = codec{marshal, unmarshal}
+}
+
+// marshalCustom encodes a type with custom encoding. The custom encodings are
+// looked up in the order below, either by the value or by its pointer when
+// addressable, and the decoding is symmetric:
+//  1. The codec registered by RegisterCodec.
+//  2. Marshaler and Unmarshaler.
+//  3. encoding.TextMarshaler and encoding.TextUnmarshaler, the text is encoded
+//     as a string.
+//  4. The built-in encoding of its kind.
+//
+// The dedicated encodings of time.Time, time.Duration, json.RawMessage and the
+// registered tuples are kept before them.
+func (enc *Encoder) marshalCustom(v reflect.Value) (core.List, bool, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() || !v.CanInterface() {
+		return nil, false, nil
+	}
+	if c, ok := enc.registry.lookupCodec(v.Type()); ok {
+		list, err := parseCustom(c.marshal(v.Interface()))
+		return list, true, err
+	}
+	if m, ok := implements(v, marshalerType); ok {
+		list, err := parseCustom(m.(Marshaler).MarshalTEFF())
+		return list, true, err
+	}
+	if m, ok := implements(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, true, err
+		}
+		node, err := enc.marshalNode(reflect.ValueOf(string(text)))
+		return core.List{node}, true, err
+	}
+	if node, ok, err := enc.marshalErrorChain(v); ok {
+		return core.List{node}, true, err
+	}
+	return nil, false, nil
+}
+
+// hasCustom returns true if v or a value it points to has a custom encoding,
+// e.g. a map type that must not be encoded entry by entry.
+func (enc *Encoder) hasCustom(v reflect.Value) bool {
+	for {
+		if _, ok := enc.registry.lookupCodec(v.Type()); ok {
+			return true
+		}
+		if !v.CanInterface() {
+			return false
+		}
+		if _, ok := implements(v, marshalerType); ok {
+			return true
+		}
+		if _, ok := implements(v, textMarshalerType); ok {
+			return true
+		}
+		if _, ok := implements(v, errorType); ok && enc.errorMessages {
+			return true
+		}
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+}
+
+func parseCustom(data []byte, err error) (core.List, error) {
+	if err != nil {
+		return nil, err
+	}
+	return core.Parse(bytes.NewReader(data))
+}
+
+func (enc *Encoder) marshalCustomNode(v reflect.Value) (core.Node, bool, error) {
+	list, ok, err := enc.marshalCustom(v)
+	if !ok || err != nil {
+		return core.Node{}, ok, err
+	}
+	if len(list) == 1 {
+		if _, _, kv := splitKeyValue(list[0]); !kv {
+			return list[0], true, nil
+		}
+	}
+	return core.Node{Value: "_", List: list}, true, nil
+}
+
+func (dec *Decoder) unmarshalCustom(list core.List, v reflect.Value) (bool, error) {
+	if v.Kind() == reflect.Ptr || !v.CanAddr() || !v.Addr().CanInterface() {
+		return false, nil
+	}
+	if c, ok := dec.registry.lookupCodec(v.Type()); ok {
+		return true, c.unmarshal([]byte(list.String()), v.Addr().Interface())
+	}
+	if u, ok := implements(v.Addr(), unmarshalerType); ok {
+		return true, u.(Unmarshaler).UnmarshalTEFF([]byte(list.String()))
+	}
+	if u, ok := implements(v.Addr(), textUnmarshalerType); ok {
+		var s string
+		if err := dec.unmarshalScalar(list, reflect.ValueOf(&s).Elem()); err != nil {
+			return true, err
+		}
+		return true, u.(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	return false, nil
+}
+
+func (dec *Decoder) unmarshalCustomNode(node core.Node, v reflect.Value) (bool, error) {
+	return dec.unmarshalCustom(customList(node), v)
+}
+
+// customList returns the list passed to a custom decoding for a node, i.e. the
+// elements of an anonymous composite or the node itself.
+func customList(node core.Node) core.List {
+	if node.Value == "_" && !node.IsReference && len(node.List) > 0 {
+		return node.List
+	}
+	return core.List{node}
+}
+
+// SetFallback sets the decoding of the types that are not supported otherwise,
+// e.g. to keep the TEFF text of an unknown type. unmarshal is called as the
+// unmarshal function of RegisterCodec.
+func (dec *Decoder) SetFallback(unmarshal func([]byte, interface{}) error) {
+	dec.fallback = unmarshal
+}
+
+func (dec *Decoder) unmarshalFallback(list core.List, v reflect.Value) error {
+	if dec.fallback == nil || !v.CanAddr() || !v.Addr().CanInterface() {
+		return fmt.Errorf("unmarshal unsupported")
+	}
+	return dec.fallback([]byte(list.String()), v.Addr().Interface())
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errorType           = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// implements returns v or its address as an interface{} if it implements t.
+func implements(v reflect.Value, t reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(t) {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(t) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
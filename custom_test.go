@@ -0,0 +1,180 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// both implements both Marshaler and encoding.TextMarshaler.
+type both struct {
+	A, B string
+}
+
+func (b both) MarshalTEFF() ([]byte, error) {
+	return []byte("ab:\n\t" + b.A + "\n\t" + b.B), nil
+}
+
+func (b *both) UnmarshalTEFF(data []byte) error {
+	var v struct{ AB []string }
+	if err := Unmarshal([]byte(strings.Replace(string(data), "ab:", "AB:", 1)), &v); err != nil {
+		return err
+	}
+	b.A, b.B = v.AB[0], v.AB[1]
+	return nil
+}
+
+func (b both) MarshalText() ([]byte, error) {
+	return []byte(b.A + "-" + b.B), nil
+}
+
+func (b *both) UnmarshalText(text []byte) error {
+	s := strings.SplitN(string(text), "-", 2)
+	b.A, b.B = s[0], s[1]
+	return nil
+}
+
+// text implements only encoding.TextMarshaler.
+type text struct {
+	A, B string
+}
+
+func (t text) MarshalText() ([]byte, error) {
+	return []byte(t.A + "-" + t.B), nil
+}
+
+func (t *text) UnmarshalText(data []byte) error {
+	s := strings.SplitN(string(data), "-", 2)
+	t.A, t.B = s[0], s[1]
+	return nil
+}
+
+// flags is a map implementing encoding.TextMarshaler.
+type flags map[string]bool
+
+func (f flags) MarshalText() ([]byte, error) {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return []byte(strings.Join(keys, ",")), nil
+}
+
+func (f *flags) UnmarshalText(data []byte) error {
+	*f = make(flags)
+	for _, k := range strings.Split(string(data), ",") {
+		(*f)[k] = true
+	}
+	return nil
+}
+
+func TestCustomMarshaler(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{both{"a", "b"}, "ab:\n\ta\n\tb"},
+		{[]both{{"a", "b"}}, "_\n\tab:\n\t\ta\n\t\tb"},
+		{struct{ P *both }{&both{"a", "b"}}, "P:\n\tab:\n\t\ta\n\t\tb"},
+		{text{"a", "b"}, "a-b"},
+		{[]text{{"a", "b"}, {"c", "d"}}, "a-b\nc-d"},
+		{struct{ T text }{text{"a", "1"}}, "T:\n\ta-1"},
+		{flags{"b": true, "a": true}, "a,b"},
+		{struct{ F flags }{flags{"a": true}}, "F:\n\ta"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+// decimal stands in for a type of another package.
+type decimal struct {
+	units int
+	cents int
+}
+
+func init() {
+	RegisterCodec(reflect.TypeOf(decimal{}), func(v interface{}) ([]byte, error) {
+		d := v.(decimal)
+		return []byte(fmt.Sprintf("%d.%02d", d.units, d.cents)), nil
+	}, func(data []byte, v interface{}) error {
+		d := v.(*decimal)
+		_, err := fmt.Sscanf(string(data), "%d.%d", &d.units, &d.cents)
+		return err
+	})
+}
+
+func TestRegisterCodec(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{decimal{3, 5}, "3.05"},
+		{[]decimal{{1, 0}, {2, 50}}, "1.00\n2.50"},
+		{struct{ Price *decimal }{&decimal{9, 99}}, "Price:\n\t9.99"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	type record struct {
+		Name  string
+		Count uint
+		Phase complex128
+	}
+	text := "Name:\n\ta\nCount:\n\t3\nPhase:\n\t(1+2i)"
+	var v record
+	if err := Unmarshal([]byte(text), &v); err == nil {
+		t.Fatal("expect error for unsupported types")
+	}
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetFallback(func(data []byte, v interface{}) error {
+		switch v := v.(type) {
+		case *uint:
+			n, err := strconv.ParseUint(string(data), 10, 0)
+			*v = uint(n)
+			return err
+		case *complex128:
+			c, err := strconv.ParseComplex(string(data), 128)
+			*v = c
+			return err
+		}
+		return fmt.Errorf("unsupported %T", v)
+	})
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (record{"a", 3, 1 + 2i}); v != expected {
+		t.Fatalf("expect %#v but got %#v", expected, v)
+	}
+}
@@ -0,0 +1,127 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"h12.io/teff/core"
+)
+
+// DescribeType returns a schema of the type of v in the format of
+// ValidateSchema, which documents what the type decodes from. v may be a
+// reflect.Type or a value of the type, e.g. a nil pointer to a struct.
+//
+// Each struct field is annotated with its Go type and its teff tag, and is
+// optional unless tagged required. The values that the schema cannot describe
+// exactly, e.g. a map or a type of custom encoding, are described as any, and
+// the scalars other than int and bool are described as string.
+func DescribeType(v interface{}) ([]byte, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("teff: DescribeType(nil)")
+	}
+	list, err := (&describer{visiting: make(map[reflect.Type]bool)}).describe(t)
+	if err != nil {
+		return nil, err
+	}
+	var w bytes.Buffer
+	if err := NewEncoder(&w).writeList(list); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+type describer struct {
+	visiting map[reflect.Type]bool
+}
+
+func (d *describer) describe(t reflect.Type) (core.List, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if globalRegistry.isLeafType(t) {
+		return typeSchema("string"), nil
+	}
+	if _, ok := globalRegistry.lookupCodec(t); ok || implementsAny(t, marshalerType, unmarshalerType) {
+		return typeSchema("any"), nil
+	}
+	if implementsAny(t, textMarshalerType, textUnmarshalerType) {
+		return typeSchema("string"), nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return typeSchema("bool"), nil
+	case reflect.Int:
+		if _, ok := globalRegistry.lookupEnum(t); ok {
+			return typeSchema("string"), nil
+		}
+		return typeSchema("int"), nil
+	case reflect.Float32, reflect.Float64, reflect.String:
+		return typeSchema("string"), nil
+	case reflect.Slice, reflect.Array, reflect.Chan:
+		elem, err := d.describe(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return core.List{{Value: "[]", List: elem}}, nil
+	case reflect.Struct:
+		if d.visiting[t] || globalRegistry.isPositional(t) {
+			return typeSchema("any"), nil
+		}
+		d.visiting[t] = true
+		defer delete(d.visiting, t)
+		return d.describeStruct(t)
+	case reflect.Map, reflect.Interface:
+		return typeSchema("any"), nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", t)
+}
+
+func (d *describer) describeStruct(t reflect.Type) (core.List, error) {
+	var list core.List
+	for _, f := range structFields(t) {
+		if f.comments || f.method != "" {
+			continue
+		}
+		sf := t.FieldByIndex(f.index)
+		value, err := d.describe(sf.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		if f.unit != "" {
+			value = typeSchema("string")
+		}
+		key := f.name + "?:"
+		if f.required {
+			key = f.name + ":"
+		}
+		annotation := " " + sf.Type.String()
+		if tag, ok := sf.Tag.Lookup("teff"); ok {
+			annotation += " teff:" + strconv.Quote(tag)
+		}
+		list = append(list, core.Node{Value: key, List: value, Annotations: []string{annotation}})
+	}
+	if len(list) == 0 {
+		return typeSchema("any"), nil
+	}
+	return list, nil
+}
+
+func typeSchema(name string) core.List {
+	return core.List{{Value: name}}
+}
+
+// implementsAny returns true if t or its pointer implements any of types.
+func implementsAny(t reflect.Type, types ...reflect.Type) bool {
+	for _, it := range types {
+		if t.Implements(it) || reflect.PtrTo(t).Implements(it) {
+			return true
+		}
+	}
+	return false
+}
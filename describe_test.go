@@ -0,0 +1,72 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDescribeType(t *testing.T) {
+	type server struct {
+		Host string `teff:"host,required"`
+		Port int
+	}
+	type config struct {
+		Name    string
+		Debug   bool
+		Timeout time.Duration
+		Servers []server
+		Labels  map[string]string
+		Primary *server `teff:"primary"`
+	}
+	expected := `# string
+Name?:
+	string
+# bool
+Debug?:
+	bool
+# time.Duration
+Timeout?:
+	string
+# []teff.server
+Servers?:
+	[]
+		# string teff:"host,required"
+		host:
+			string
+		# int
+		Port?:
+			int
+# map[string]string
+Labels?:
+	any
+# *teff.server teff:"primary"
+primary?:
+	# string teff:"host,required"
+	host:
+		string
+	# int
+	Port?:
+		int`
+	for _, v := range []interface{}{config{}, (*config)(nil), reflect.TypeOf(config{})} {
+		buf, err := DescribeType(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != expected {
+			t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+		}
+	}
+	schema, _ := DescribeType(config{})
+	doc, err := Marshal(config{Name: "a", Timeout: time.Second, Servers: []server{{"h", 80}}, Primary: &server{Host: "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := ValidateSchema(doc, schema)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("expect the document to be valid but got %v %v", errs, err)
+	}
+	if _, err := DescribeType(struct{ F func() }{}); err == nil {
+		t.Fatal("expect error for an unsupported type")
+	}
+}
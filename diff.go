@@ -0,0 +1,157 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+// MarshalDiff returns a patch of the values in updated that differ from base,
+// which restores updated when decoded into a copy of base with SetMerge:
+//   - a struct is diffed field by field, and only the changed fields are
+//     encoded;
+//   - a map is diffed entry by entry, and only the added or changed entries are
+//     encoded. A removed entry cannot be expressed and is an error;
+//   - non-nil pointers are diffed by their elements;
+//   - any other value, including a slice, is encoded as a whole if it changes.
+//
+// base and updated must be non-nil values of the same type. The patch is empty
+// if they are equal.
+func MarshalDiff(base, updated interface{}) ([]byte, error) {
+	b, u := reflect.ValueOf(base), reflect.ValueOf(updated)
+	if !b.IsValid() || !u.IsValid() {
+		return nil, fmt.Errorf("teff: diff of nil interface values %v and %v", base, updated)
+	}
+	if b.Type() != u.Type() {
+		return nil, fmt.Errorf("teff: diff of different types %v and %v", b.Type(), u.Type())
+	}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	list, _, err := enc.diff(b, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.writeList(list); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// diff returns the patch of u against b and whether they differ.
+func (enc *Encoder) diff(b, u reflect.Value) (core.List, bool, error) {
+	if reflect.DeepEqual(b.Interface(), u.Interface()) {
+		return nil, false, nil
+	}
+	if !enc.registry.isDiffable(u.Type()) {
+		list, err := enc.marshalList(u)
+		return list, true, err
+	}
+	switch u.Kind() {
+	case reflect.Ptr:
+		if b.IsNil() || u.IsNil() {
+			break
+		}
+		return enc.diff(b.Elem(), u.Elem())
+	case reflect.Struct:
+		return enc.diffStruct(b, u)
+	case reflect.Map:
+		if b.IsNil() || u.IsNil() {
+			break
+		}
+		return enc.diffMap(b, u)
+	}
+	list, err := enc.marshalList(u)
+	return list, true, err
+}
+
+func (enc *Encoder) diffStruct(b, u reflect.Value) (core.List, bool, error) {
+	var list core.List
+	fields := structFields(u.Type())
+	if enc.jsonTags {
+		fields = applyJSONTags(fields)
+	}
+	for _, f := range fields {
+		if f.comments || f.method != "" {
+			continue
+		}
+		uf, ok := fieldByIndex(u, f.index)
+		if !ok {
+			continue
+		}
+		bf, ok := fieldByIndex(b, f.index)
+		if !ok {
+			bf = reflect.Zero(uf.Type())
+		}
+		var value core.List
+		var changed bool
+		var err error
+		if f.timeLayout != "" || f.unit != "" || f.secret {
+			if changed = !reflect.DeepEqual(bf.Interface(), uf.Interface()); changed {
+				value, err = enc.marshalField(f, uf)
+			}
+		} else {
+			value, changed, err = enc.diff(bf, uf)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if changed {
+			list = append(list, enc.keyValue(quoteKey(f.encodedName(enc.fieldNamer)), uf.Type(), value))
+		}
+	}
+	if enc.align {
+		alignValues(list)
+	}
+	return list, len(list) > 0, nil
+}
+
+func (enc *Encoder) diffMap(b, u reflect.Value) (core.List, bool, error) {
+	for _, key := range b.MapKeys() {
+		if !u.MapIndex(key).IsValid() {
+			return nil, false, fmt.Errorf("teff: diff cannot remove map key %v", key.Interface())
+		}
+	}
+	entries, err := enc.mapEntries(u)
+	if err != nil {
+		return nil, false, err
+	}
+	var list core.List
+	for _, e := range entries {
+		uv := u.MapIndex(e.key)
+		var value core.List
+		changed := true
+		if bv := b.MapIndex(e.key); bv.IsValid() {
+			value, changed, err = enc.diff(bv, uv)
+		} else {
+			value, err = enc.marshalList(uv)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if changed {
+			list = append(list, enc.keyValue(e.name, u.Type().Elem(), value))
+		}
+	}
+	if enc.align {
+		alignValues(list)
+	}
+	return list, len(list) > 0, nil
+}
+
+// isDiffable returns true if a value of type t is encoded by its kind as a
+// struct, a map or a pointer to them, rather than by a custom encoding.
+func (r *Registry) isDiffable(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map || r.isLeafType(t) || r.isPositional(t) {
+		return false
+	}
+	if _, ok := r.lookupCodec(t); ok {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !pt.Implements(marshalerType) && !pt.Implements(textMarshalerType)
+}
@@ -0,0 +1,75 @@
+package teff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalDiff(t *testing.T) {
+	type server struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name    string
+		Server  server
+		Backup  *server
+		Tags    []string
+		Limits  map[string]int
+		Comment string
+	}
+	base := config{
+		Name:   "a",
+		Server: server{"h", 80},
+		Backup: &server{"b", 80},
+		Tags:   []string{"x", "y"},
+		Limits: map[string]int{"cpu": 1, "mem": 2},
+	}
+	for i, testcase := range []struct {
+		update func(c *config)
+		patch  string
+	}{
+		{func(c *config) {}, ""},
+		{func(c *config) { c.Server.Port = 443 }, "Server:\n\tPort:\n\t\t443"},
+		{func(c *config) { c.Backup = &server{"b", 81} }, "Backup:\n\tPort:\n\t\t81"},
+		{func(c *config) { c.Tags = []string{"x"} }, "Tags:\n\tx"},
+		{func(c *config) { c.Limits = map[string]int{"cpu": 1, "mem": 4, "io": 3} }, "Limits:\n\tio:\n\t\t3\n\tmem:\n\t\t4"},
+		{func(c *config) { c.Name, c.Backup = "b", nil }, "Name:\n\tb\nBackup:\n\tnil"},
+	} {
+		updated := base
+		updated.Server = base.Server
+		updated.Backup = &server{}
+		*updated.Backup = *base.Backup
+		updated.Limits = map[string]int{"cpu": 1, "mem": 2}
+		testcase.update(&updated)
+		buf, err := MarshalDiff(base, updated)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.patch {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.patch, buf)
+		}
+		patched := base
+		patched.Backup = &server{}
+		*patched.Backup = *base.Backup
+		patched.Limits = map[string]int{"cpu": 1, "mem": 2}
+		dec := NewDecoder(bytes.NewReader(buf))
+		dec.SetMerge(true)
+		if err := dec.Decode(&patched); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(patched, updated) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, updated, patched)
+		}
+	}
+	if _, err := MarshalDiff(map[string]int{"a": 1}, map[string]int{}); err == nil {
+		t.Fatal("expect error for a removed map key")
+	}
+	if _, err := MarshalDiff(nil, 1); err == nil {
+		t.Fatal("expect error for a nil base")
+	}
+	if _, err := MarshalDiff(1, nil); err == nil {
+		t.Fatal("expect error for a nil updated value")
+	}
+}
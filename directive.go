@@ -0,0 +1,54 @@
+package teff
+
+import (
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// Directive is a structured annotation in the form of "# @name value", e.g.
+// "# @deprecated since=2.0".
+type Directive struct {
+	Name  string
+	Value string
+}
+
+// SetDirectives sets whether the directives in annotations are collected while
+// decoding, and returned by Directives.
+func (dec *Decoder) SetDirectives(collect bool) {
+	dec.directives = collect
+}
+
+// Directives returns the directives collected by the last decoding, keyed by
+// the path of the annotated node, e.g. server.ports[1], where ^ is the root. A
+// directive above a key belongs to the value of the key, and a single value
+// shares the path of its parent.
+func (dec *Decoder) Directives() map[string][]Directive {
+	return dec.directiveMap
+}
+
+func (dec *Decoder) collectDirectives(list core.List) {
+	walkPaths(list, "", func(node core.Node, path string) {
+		for _, a := range node.Annotations {
+			if d, ok := parseDirective(a); ok {
+				if dec.directiveMap == nil {
+					dec.directiveMap = make(map[string][]Directive)
+				}
+				p := pathOrRoot(path)
+				dec.directiveMap[p] = append(dec.directiveMap[p], d)
+			}
+		}
+	})
+}
+
+func parseDirective(annotation string) (Directive, bool) {
+	s := strings.TrimSpace(annotation)
+	if !strings.HasPrefix(s, "@") || len(s) == 1 {
+		return Directive{}, false
+	}
+	s = s[1:]
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return Directive{Name: s[:i], Value: strings.TrimSpace(s[i+1:])}, true
+	}
+	return Directive{Name: s}, true
+}
@@ -0,0 +1,41 @@
+package teff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	text := `# @version 2
+# @deprecated since=2.0
+OldPort:
+	80
+Server:
+	Ports:
+		443
+		# @internal
+		8443
+	# plain comment
+	Host:
+		a`
+	var v struct {
+		OldPort int
+		Server  struct {
+			Ports []int
+			Host  string
+		}
+	}
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetDirectives(true)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]Directive{
+		"OldPort":         {{"version", "2"}, {"deprecated", "since=2.0"}},
+		"Server.Ports[1]": {{"internal", ""}},
+	}
+	if !reflect.DeepEqual(dec.Directives(), expected) {
+		t.Fatalf("expect %v but got %v", expected, dec.Directives())
+	}
+}
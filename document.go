@@ -0,0 +1,83 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"h12.io/teff/core"
+)
+
+var (
+	documentLock  sync.RWMutex
+	documentTypes = make(map[string]reflect.Type)
+	documentNames = make(map[reflect.Type]string)
+)
+
+// RegisterDocument registers the name of a document type t, so that a document
+// starting with a line of the name is decoded as t by UnmarshalDocument, e.g.
+// versioned config files:
+//
+//	ConfigV2
+//	Name:
+//		a
+func RegisterDocument(name string, t reflect.Type) {
+	if !isRawString(name) || isLiteral(name) {
+		panic(fmt.Sprintf("teff: registering invalid document name %q for %v", name, t))
+	}
+	documentLock.Lock()
+	defer documentLock.Unlock()
+	if _, ok := documentTypes[name]; ok {
+		panic(fmt.Sprintf("teff: registering duplicate document name %q", name))
+	}
+	documentTypes[name] = t
+	documentNames[t] = name
+}
+
+// MarshalDocument is like Marshal but writes the registered name of the type
+// of v as the first line.
+func MarshalDocument(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	documentLock.RLock()
+	name, ok := documentNames[t]
+	documentLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("teff: unregistered document type %v", t)
+	}
+	buf, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return []byte(name), nil
+	}
+	return append([]byte(name+"\n"), buf...), nil
+}
+
+// UnmarshalDocument decodes data of a type registered by RegisterDocument,
+// chosen by the name in the first line, and returns a pointer to the value.
+func UnmarshalDocument(data []byte) (interface{}, error) {
+	list, err := core.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 || list[0].IsReference || len(list[0].List) > 0 {
+		return nil, fmt.Errorf("teff: missing document name")
+	}
+	name := list[0].Value
+	documentLock.RLock()
+	t, ok := documentTypes[name]
+	documentLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("teff: unknown document name %s", name)
+	}
+	v := reflect.New(t)
+	dec := NewDecoder(nil)
+	if rest := list[1:]; !dec.isNil(rest) {
+		if err := dec.unmarshalList(rest, v); err != nil {
+			return nil, err
+		}
+	}
+	return v.Interface(), nil
+}
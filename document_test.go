@@ -0,0 +1,48 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+type configV1 struct {
+	Host string
+}
+
+type configV2 struct {
+	Host string
+	Port int
+}
+
+func init() {
+	RegisterDocument("ConfigV1", reflect.TypeOf(configV1{}))
+	RegisterDocument("ConfigV2", reflect.TypeOf(configV2{}))
+}
+
+func TestDocument(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{configV1{"a"}, "ConfigV1\nHost:\n\ta"},
+		{configV2{"b", 80}, "ConfigV2\nHost:\n\tb\nPort:\n\t80"},
+	} {
+		buf, err := MarshalDocument(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		v, err := UnmarshalDocument(buf)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(v).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+	if _, err := UnmarshalDocument([]byte("ConfigV3\nHost:\n\ta")); err == nil {
+		t.Fatal("expect error for an unknown document name")
+	}
+}
@@ -0,0 +1,106 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+type enum struct {
+	names  map[int64]string
+	values map[string]int64
+}
+
+// RegisterEnum registers the names of the values of an int type t, so that a
+// value of t is encoded as its name, and a name is decoded back to its value.
+// A value without a name is still encoded as an integer.
+func RegisterEnum(t reflect.Type, names map[int64]string) {
+	globalRegistry.RegisterEnum(t, names)
+}
+
+// RegisterEnum is like the function RegisterEnum but registers into r.
+func (r *Registry) RegisterEnum(t reflect.Type, names map[int64]string) {
+	if t.Kind() != reflect.Int {
+		panic(fmt.Sprintf("teff: registering enum of non-int type %v", t))
+	}
+	e := &enum{
+		names:  make(map[int64]string, len(names)),
+		values: make(map[string]int64, len(names)),
+	}
+	for value, name := range names {
+		if !isRawString(name) || isLiteral(name) {
+			panic(fmt.Sprintf("teff: registering invalid enum name %q for %v", name, t))
+		}
+		if _, ok := e.values[name]; ok {
+			panic(fmt.Sprintf("teff: registering duplicate enum name %q for %v", name, t))
+		}
+		e.names[value] = name
+		e.values[name] = value
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enums[t] = e
+}
+
+// SetUnknownEnumZero sets whether a value of a registered enum type that is
+// neither a name nor an integer is decoded as zero rather than an error, e.g.
+// for a name added by a newer version.
+func (dec *Decoder) SetUnknownEnumZero(zero bool) {
+	dec.enumZero = zero
+}
+
+// SetEnumCaseInsensitive sets whether a name of a registered enum type matches
+// case insensitively when there is no exact match, e.g. RED or red matches Red.
+func (dec *Decoder) SetEnumCaseInsensitive(fold bool) {
+	dec.enumFold = fold
+}
+
+func (dec *Decoder) unmarshalEnum(e *enum, node core.Node, v reflect.Value) error {
+	if i, ok := e.values[node.Value]; ok {
+		v.SetInt(i)
+		return nil
+	}
+	if dec.enumFold {
+		var matches []string
+		for name := range e.values {
+			if strings.EqualFold(name, node.Value) {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) > 1 {
+			sort.Strings(matches)
+			return fmt.Errorf("ambiguous %v value %s matching %s", v.Type(), node.Value, strings.Join(matches, ", "))
+		}
+		if len(matches) == 1 {
+			v.SetInt(e.values[matches[0]])
+			return nil
+		}
+	}
+	i, err := dec.parseInt(node.Value)
+	if err != nil {
+		if dec.enumZero {
+			v.SetInt(0)
+			return nil
+		}
+		return fmt.Errorf("unknown %v value %s", v.Type(), node.Value)
+	}
+	v.SetInt(int64(i))
+	return nil
+}
+
+// isRawString returns true if s can be encoded as a raw string as defined in
+// the spec, and would not be unquoted when decoded.
+func isRawString(s string) bool {
+	if s == "" || !strconv.CanBackquote(s) {
+		return false
+	}
+	switch s[0] {
+	case ' ', '\t', '#', '^', '"', '`', '\'':
+		return false
+	}
+	return true
+}
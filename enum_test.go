@@ -0,0 +1,128 @@
+package teff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type color int
+
+const (
+	red color = iota
+	green
+	blue
+)
+
+func init() {
+	RegisterEnum(reflect.TypeOf(color(0)), map[int64]string{
+		int64(red):   "red",
+		int64(green): "green",
+		int64(blue):  "blue",
+	})
+}
+
+func TestEnum(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{red, "red"},
+		{[]color{green, blue, color(5)}, "green\nblue\n5"},
+		{struct{ C color }{blue}, "C:\n\tblue"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestUnknownEnum(t *testing.T) {
+	for i, testcase := range []struct {
+		text  string
+		zero  bool
+		value color
+		err   bool
+	}{
+		{"blue", false, blue, false},
+		{"2", false, blue, false},
+		{"purple", false, 0, true},
+		{"blue", true, blue, false},
+		{"2", true, blue, false},
+		{"purple", true, red, false},
+	} {
+		c := green
+		dec := NewDecoder(strings.NewReader(testcase.text))
+		dec.SetUnknownEnumZero(testcase.zero)
+		err := dec.Decode(&c)
+		if (err != nil) != testcase.err {
+			t.Fatalf("testcase %d: expect error %v but got %v", i, testcase.err, err)
+		}
+		if err == nil && c != testcase.value {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.value, c)
+		}
+	}
+}
+
+func TestRegisterEnumPanic(t *testing.T) {
+	type kind int
+	for i, names := range []map[int64]string{
+		{0: "a", 1: "a"},
+		{0: "1"},
+		{0: "#a"},
+		{0: ""},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("testcase %d: expect panic", i)
+				}
+			}()
+			RegisterEnum(reflect.TypeOf(kind(0)), names)
+		}()
+	}
+}
+
+type shade int
+
+func init() {
+	RegisterEnum(reflect.TypeOf(shade(0)), map[int64]string{0: "Dark", 1: "DARK", 2: "Light"})
+}
+
+func TestEnumCaseInsensitive(t *testing.T) {
+	for i, testcase := range []struct {
+		text  string
+		fold  bool
+		value shade
+		err   bool
+	}{
+		{"LIGHT", false, 0, true},
+		{"LIGHT", true, 2, false},
+		{"light", true, 2, false},
+		{"Dark", true, 0, false},
+		{"DARK", true, 1, false},
+		{"dark", true, 0, true},
+	} {
+		var v shade
+		dec := NewDecoder(strings.NewReader(testcase.text))
+		dec.SetEnumCaseInsensitive(testcase.fold)
+		err := dec.Decode(&v)
+		if (err != nil) != testcase.err {
+			t.Fatalf("testcase %d: expect error %v but got %v", i, testcase.err, err)
+		}
+		if v != testcase.value {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.value, v)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package teff
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// SetErrorMessages sets whether a value implementing error is encoded as its
+// message, with the chain of the errors it wraps, as returned by errors.Unwrap,
+// nested under it one level each:
+//
+//	load config: open a.teff: permission denied
+//		open a.teff: permission denied
+//			permission denied
+//
+// It is meant for diagnostic dumps: the errors cannot be decoded back.
+func (enc *Encoder) SetErrorMessages(messages bool) {
+	enc.errorMessages = messages
+}
+
+func (enc *Encoder) marshalErrorChain(v reflect.Value) (core.Node, bool, error) {
+	if !enc.errorMessages {
+		return core.Node{}, false, nil
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() || !v.CanInterface() {
+		return core.Node{}, false, nil
+	}
+	err, ok := v.Interface().(error)
+	if !ok && v.CanAddr() {
+		err, ok = v.Addr().Interface().(error)
+	}
+	if !ok {
+		return core.Node{}, false, nil
+	}
+	node, e := enc.errorNode(err)
+	return node, true, e
+}
+
+func (enc *Encoder) errorNode(err error) (core.Node, error) {
+	node, e := enc.marshalNode(reflect.ValueOf(err.Error()))
+	if e != nil {
+		return core.Node{}, e
+	}
+	// A block string or a value like a key cannot have the wrapped errors as
+	// its children.
+	if len(node.List) > 0 || strings.HasSuffix(node.Value, ":") {
+		node = core.Node{Value: strconv.Quote(err.Error())}
+	}
+	if next := errors.Unwrap(err); next != nil {
+		child, e := enc.errorNode(next)
+		if e != nil {
+			return core.Node{}, e
+		}
+		node.List = core.List{child}
+	}
+	return node, nil
+}
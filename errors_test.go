@@ -0,0 +1,41 @@
+package teff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorMessages(t *testing.T) {
+	inner := errors.New("permission denied")
+	middle := fmt.Errorf("open a.teff: %w", inner)
+	outer := fmt.Errorf("load config: %w", middle)
+	type report struct {
+		Err   error
+		Cause error
+		Errs  []interface{}
+	}
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{inner, "permission denied"},
+		{outer, "load config: open a.teff: permission denied\n\topen a.teff: permission denied\n\t\tpermission denied"},
+		{
+			report{Err: middle, Errs: []interface{}{fmt.Errorf("retry:%w", errors.New("a\nb")), 1}},
+			"Err:\n\topen a.teff: permission denied\n\t\tpermission denied\nCause:\n\tnil\nErrs:\n\t\"retry:a\\nb\"\n\t\t\"a\\nb\"\n\t1",
+		},
+		{errors.New("failed:"), `"failed:"`},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetErrorMessages(true)
+		if err := enc.Encode(testcase.value); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, w.String())
+		}
+	}
+}
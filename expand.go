@@ -0,0 +1,36 @@
+package teff
+
+import "strings"
+
+// SetExpander sets the function used to expand ${name} in decoded strings, and
+// $$ is expanded to a literal $. The expansion is disabled when expander is
+// nil, which is the default. os.Getenv can be used as expander to expand
+// environment variables.
+func (dec *Decoder) SetExpander(expander func(name string) string) {
+	dec.expander = expander
+}
+
+func expand(s string, mapping func(string) string) string {
+	if strings.IndexByte(s, '$') < 0 {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '$' && i+1 < len(s) {
+			switch s[i+1] {
+			case '$':
+				buf = append(buf, '$')
+				i++
+				continue
+			case '{':
+				if j := strings.IndexByte(s[i+2:], '}'); j >= 0 {
+					buf = append(buf, mapping(s[i+2:i+2+j])...)
+					i += j + 2
+					continue
+				}
+			}
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
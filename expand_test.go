@@ -0,0 +1,45 @@
+package teff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "PORT": "80"}
+	mapping := func(name string) string { return env[name] }
+	for i, testcase := range []struct {
+		text     string
+		expected string
+	}{
+		{`a`, "a"},
+		{`${HOST}`, "example.com"},
+		{`"http://${HOST}:${PORT}/"`, "http://example.com:80/"},
+		{`${USER}`, ""},
+		{`x${USER}y`, "xy"},
+		{`$$`, "$"},
+		{`$${HOST}`, "${HOST}"},
+		{`$HOST`, "$HOST"},
+		{`${HOST`, "${HOST"},
+		{`a$`, "a$"},
+	} {
+		var s string
+		dec := NewDecoder(bytes.NewReader([]byte(testcase.text)))
+		dec.SetExpander(mapping)
+		if err := dec.Decode(&s); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s != testcase.expected {
+			t.Fatalf("testcase %d: expect %q but got %q", i, testcase.expected, s)
+		}
+	}
+	{
+		var s string
+		if err := Unmarshal([]byte(`${HOST}`), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s != "${HOST}" {
+			t.Fatalf("expect no expansion by default but got %q", s)
+		}
+	}
+}
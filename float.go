@@ -0,0 +1,69 @@
+package teff
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// marshalFloat encodes a float in the shortest form that decodes to the same
+// value unless a precision is set, and NaN and infinities as NaN, +Inf and
+// -Inf.
+func (enc *Encoder) marshalFloat(v reflect.Value) (core.Node, error) {
+	f := v.Float()
+	if enc.canonical && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return core.Node{}, fmt.Errorf("canonical mode: %v has no canonical encoding", f)
+	}
+	if enc.floatPrec >= 0 {
+		return core.Node{Value: strconv.FormatFloat(f, 'f', enc.floatPrec, v.Type().Bits())}, nil
+	}
+	return core.Node{Value: formatFloat(f, v.Type().Bits())}, nil
+}
+
+// formatFloat formats f in the shortest form as specified in the spec, which is
+// the same as strconv.FormatFloat(f, 'g', -1, bitSize), but the layout is
+// pinned here rather than depending on strconv, so that the output is stable
+// across Go versions. strconv only provides the shortest decimal digits that
+// decode to f, which are uniquely defined.
+func formatFloat(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'e', -1, bitSize)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return s
+	}
+	i := strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[i+1:])
+	if exp < -4 || exp >= 6 {
+		return s
+	}
+	sign, mantissa := "", s[:i]
+	if mantissa[0] == '-' {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	digits := strings.Replace(mantissa, ".", "", 1)
+	if exp < 0 {
+		return sign + "0." + strings.Repeat("0", -exp-1) + digits
+	}
+	if len(digits) <= exp+1 {
+		return sign + digits + strings.Repeat("0", exp+1-len(digits))
+	}
+	return sign + digits[:exp+1] + "." + digits[exp+1:]
+}
+
+// SetFloatPrecision sets the number of digits after the decimal point of a
+// float, e.g. 3.14159 is encoded as 3.14 with precision 2. A negative
+// precision, the default, means the shortest form that decodes to the same
+// value.
+func (enc *Encoder) SetFloatPrecision(prec int) {
+	enc.floatPrec = prec
+}
+
+func (dec *Decoder) parseFloat(s string, bitSize int) (float64, error) {
+	if !dec.strict {
+		s = unquote(s)
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
@@ -0,0 +1,161 @@
+package teff
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestFloat(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{1.5, "1.5"},
+		{float32(0.1), "0.1"},
+		{1e21, "1e+21"},
+		{-2.0, "-2"},
+		{"1.5", `"1.5"`},
+		{"1e5", `"1e5"`},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); v != testcase.value {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestFloatSpecial(t *testing.T) {
+	for i, testcase := range []struct {
+		value float64
+		text  string
+	}{
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.text, string(buf))
+		}
+		var f float64
+		if err := Unmarshal(buf, &f); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if !(f == testcase.value || math.IsNaN(f) && math.IsNaN(testcase.value)) {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.value, f)
+		}
+
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetCanonical(true)
+		if err := enc.Encode(testcase.value); err == nil {
+			t.Fatalf("testcase %d: expect error in canonical mode", i)
+		}
+	}
+}
+
+func TestFloatPrecision(t *testing.T) {
+	for i, testcase := range []struct {
+		prec  int
+		value float64
+		text  string
+	}{
+		{2, 3.14159, "3.14"},
+		{2, 1, "1.00"},
+		{0, 2.5, "2"},
+		{-1, 3.14159, "3.14159"},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetFloatPrecision(testcase.prec)
+		if err := enc.Encode(testcase.value); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.text, w.String())
+		}
+		var f float64
+		if err := Unmarshal(w.Bytes(), &f); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		prec := testcase.prec
+		buf, err := (&Codec{FloatPrecision: &prec}).Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect %s from Codec but got %s", i, testcase.text, buf)
+		}
+	}
+	if buf, err := (&Codec{}).Marshal(3.14159); err != nil || string(buf) != "3.14159" {
+		t.Fatalf("expect the default precision of Codec but got %s, %v", buf, err)
+	}
+}
+
+// TestFloatGolden pins the float format, which must not change across
+// releases.
+func TestFloatGolden(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{0.1, "0.1"},
+		{0.3, "0.3"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{100.0, "100"},
+		{123456.0, "123456"},
+		{1234567.0, "1.234567e+06"},
+		{1e20, "1e+20"},
+		{1e21, "1e+21"},
+		{1.5e300, "1.5e+300"},
+		{0.0001, "0.0001"},
+		{0.00012345, "0.00012345"},
+		{1e-05, "1e-05"},
+		{-1.25e-10, "-1.25e-10"},
+		{0.0, "0"},
+		{math.Copysign(0, -1), "-0"},
+		{math.MaxFloat64, "1.7976931348623157e+308"},
+		{math.SmallestNonzeroFloat64, "5e-324"},
+		{2.2250738585072014e-308, "2.2250738585072014e-308"},
+		{2.225073858507201e-308, "2.225073858507201e-308"},
+		{float32(0.1), "0.1"},
+		{float32(16777216), "1.6777216e+07"},
+		{float32(math.MaxFloat32), "3.4028235e+38"},
+		{float32(math.SmallestNonzeroFloat32), "1e-45"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect %s but got %s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		v := reflect.ValueOf(newValue).Elem()
+		if f := reflect.ValueOf(testcase.value).Float(); v.Float() != f || math.Signbit(v.Float()) != math.Signbit(f) {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.value, v.Interface())
+		}
+		if s := strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()); s != testcase.text {
+			t.Fatalf("testcase %d: expect the same format as strconv %s but got %s", i, s, testcase.text)
+		}
+	}
+}
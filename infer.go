@@ -0,0 +1,128 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"h12.io/teff/core"
+)
+
+// inferList decodes a list into an empty interface without a schema, as
+// ToJSON: a list of key-value pairs becomes a map[string]interface{}, a single
+// value becomes a scalar and any other list becomes a []interface{}.
+func (dec *Decoder) inferList(list core.List) (interface{}, error) {
+	list, err := dec.pairList(list)
+	if err != nil {
+		return nil, err
+	}
+	if isObject(list) {
+		m := make(map[string]interface{}, len(list))
+		for _, node := range list {
+			k, value, _ := splitKeyValue(node)
+			key, err := dec.parseString(k)
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = dec.inferList(value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	if len(list) == 1 && list[0].Value != "_" {
+		return dec.inferNode(list[0])
+	}
+	return dec.inferElems(list)
+}
+
+func (dec *Decoder) inferElems(list core.List) ([]interface{}, error) {
+	a := make([]interface{}, len(list))
+	for i, node := range list {
+		var err error
+		if a[i], err = dec.inferNode(node); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// inferNode decodes a node into an empty interface without a schema. A node of
+// a registered type name is decoded as the type, and each scalar is inferred
+// independently as a bool, an int, a float64 or a string, in that order.
+func (dec *Decoder) inferNode(node core.Node) (interface{}, error) {
+	if node.IsReference {
+		return nil, fmt.Errorf("reference ^%s cannot be decoded without a schema", node.Value)
+	}
+	if s, ok := blockString(node); ok {
+		return s, nil
+	}
+	var i interface{}
+	if ok, err := dec.unmarshalTyped(node, reflect.ValueOf(&i).Elem()); ok {
+		return i, err
+	}
+	if node.Value == "_" {
+		if isObject(node.List) {
+			return dec.inferList(node.List)
+		}
+		return dec.inferElems(node.List)
+	}
+	if len(node.List) > 0 {
+		return nil, fmt.Errorf("unexpected children of %s", node.Value)
+	}
+	if dec.isNil(core.List{node}) {
+		return nil, nil
+	}
+	if len(dec.trues) > 0 {
+		if b, ok := boolToken(node.Value, dec.trues, dec.falses); ok {
+			return b, nil
+		}
+	} else {
+		switch node.Value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	if i, err := strconv.Atoi(node.Value); err == nil {
+		return i, nil
+	}
+	if isNumber(node.Value) {
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			return f, nil
+		}
+	}
+	return dec.parseString(node.Value)
+}
+
+// isNumber returns true if s is a decimal number, so that words parsed by
+// strconv.ParseFloat such as Inf and NaN are inferred as strings.
+func isNumber(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isInferredType returns true if t is one of the types inferred without a
+// schema, i.e. an unnamed bool, int, float64, string, map[string]interface{}
+// or []interface{}, so that a value of t is encoded from an interface as is.
+func isInferredType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Float64, reflect.String:
+		return t.PkgPath() == "" && t.Name() == t.Kind().String()
+	case reflect.Map:
+		return t == inferredMapType
+	case reflect.Slice:
+		return t == inferredSliceType
+	}
+	return false
+}
+
+var (
+	inferredMapType   = reflect.TypeOf(map[string]interface{}(nil))
+	inferredSliceType = reflect.TypeOf([]interface{}(nil))
+)
@@ -0,0 +1,82 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	for i, testcase := range []struct {
+		text     string
+		target   interface{}
+		expected interface{}
+	}{
+		{"1\ntrue\nhello\n1.5\n\"2\"\nnil\nNaN", &[]interface{}{}, &[]interface{}{1, true, "hello", 1.5, "2", nil, "NaN"}},
+		{"1\n_\n\ta\n\t2\n_\n\tk:\n\t\tfalse", &[]interface{}{}, &[]interface{}{1, []interface{}{"a", 2}, map[string]interface{}{"k": false}}},
+		{"a:\n\t1\nb:\n\tx\n\ty", new(interface{}), func() *interface{} {
+			var v interface{} = map[string]interface{}{"a": 1, "b": []interface{}{"x", "y"}}
+			return &v
+		}()},
+		{"A:\n\t-3", &struct{ A interface{} }{}, &struct{ A interface{} }{-3}},
+		{"|\n\ta\n\tb", new(interface{}), func() *interface{} {
+			var v interface{} = "a\nb"
+			return &v
+		}()},
+	} {
+		if err := Unmarshal([]byte(testcase.text), testcase.target); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(testcase.target, testcase.expected) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.expected, testcase.target)
+		}
+	}
+}
+
+func TestMarshalBasicInterface(t *testing.T) {
+	value := []interface{}{1, "x", true, 1.5, "2", nil}
+	expected := "1\nx\ntrue\n1.5\n\"2\"\nnil"
+	buf, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+	}
+	var v []interface{}
+	if err := Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, value) {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+	if _, err := Marshal([]interface{}{struct{ A int }{1}}); err == nil {
+		t.Fatal("expect error for a struct in an interface")
+	}
+}
+
+func TestMarshalInterfaceMap(t *testing.T) {
+	value := map[string]interface{}{
+		"a": 1,
+		"b": "x",
+		"c": map[string]interface{}{"d": 2.5, "e": []interface{}{true, "y"}},
+		"f": nil,
+	}
+	expected := "a:\n\t1\nb:\n\tx\nc:\n\td:\n\t\t2.5\n\te:\n\t\ttrue\n\t\ty\nf:\n\tnil"
+	buf, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+	}
+	var v map[string]interface{}
+	if err := Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, value) {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+	if _, err := Marshal(map[string]interface{}{"a": map[string]int{"b": 1}}); err == nil {
+		t.Fatal("expect error for a map of a type not inferred")
+	}
+}
@@ -0,0 +1,101 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"h12.io/teff/core"
+)
+
+// RegisterType registers the name of a concrete type t, so that a value of t or
+// *t held in an interface, e.g. a field of an interface type or an embedded
+// interface, is encoded as a node of the name with the value as its children:
+//
+//	Shape:
+//		Circle
+//			Radius:
+//				1
+//
+// and is decoded back into a value of t, or *t if only *t implements the
+// interface.
+//
+// An embedded interface is a field named by the interface type. The fields of
+// the concrete value are not promoted to the embedding struct, as they are not
+// in Go.
+func RegisterType(name string, t reflect.Type) {
+	globalRegistry.RegisterType(name, t)
+}
+
+// RegisterType is like the function RegisterType but registers into r. A name
+// or a type registered in r shadows the one in the global registry.
+func (r *Registry) RegisterType(name string, t reflect.Type) {
+	if !isRawString(name) || isLiteral(name) || name == "_" {
+		panic(fmt.Sprintf("teff: registering invalid type name %q for %v", name, t))
+	}
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("teff: registering non-concrete type %v", t))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.typeTypes[name]; ok {
+		panic(fmt.Sprintf("teff: registering duplicate type name %q", name))
+	}
+	if _, ok := r.typeNames[t]; ok {
+		panic(fmt.Sprintf("teff: registering duplicate type %v", t))
+	}
+	r.typeTypes[name] = t
+	r.typeNames[t] = name
+}
+
+// marshalTyped encodes the concrete value v of an interface if its type is
+// registered. A string equal to a registered name is quoted so that it is not
+// decoded as the type.
+func (enc *Encoder) marshalTyped(v reflect.Value) (core.Node, bool, error) {
+	if v.Kind() == reflect.String && isInferredType(v.Type()) {
+		if _, ok := enc.registry.lookupType(v.String()); ok {
+			return core.Node{Value: strconv.Quote(v.String())}, true, nil
+		}
+		return core.Node{}, false, nil
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name, ok := enc.registry.lookupTypeName(t)
+	if !ok {
+		return core.Node{}, false, nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return enc.nilNode(), true, nil
+		}
+		v = v.Elem()
+	}
+	list, err := enc.marshalList(v)
+	return core.Node{Value: name, List: list}, true, err
+}
+
+// unmarshalTyped decodes a node of a registered type name into interface v.
+func (dec *Decoder) unmarshalTyped(node core.Node, v reflect.Value) (bool, error) {
+	if node.IsReference {
+		return false, nil
+	}
+	t, ok := dec.registry.lookupType(node.Value)
+	if !ok {
+		return false, nil
+	}
+	p := reflect.New(t)
+	if err := dec.unmarshalList(node.List, p.Elem()); err != nil {
+		return true, err
+	}
+	switch {
+	case t.AssignableTo(v.Type()):
+		v.Set(p.Elem())
+	case p.Type().AssignableTo(v.Type()):
+		v.Set(p)
+	default:
+		return true, fmt.Errorf("type %s of %v does not implement %v", node.Value, t, v.Type())
+	}
+	return true, nil
+}
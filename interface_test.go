@@ -0,0 +1,96 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+type shape interface {
+	Area() float64
+}
+
+type circle struct {
+	Radius float64
+}
+
+func (c circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type rect struct {
+	Width  float64
+	Height float64
+}
+
+func (r *rect) Area() float64 { return r.Width * r.Height }
+
+type canvas struct {
+	Name string
+	shape
+	Shapes []shape
+	Any    interface{}
+}
+
+type Shape = shape
+
+type exportedCanvas struct {
+	Shape
+	Color string
+}
+
+func init() {
+	RegisterType("Circle", reflect.TypeOf(circle{}))
+	RegisterType("Rect", reflect.TypeOf(rect{}))
+}
+
+func TestInterface(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{
+			exportedCanvas{Shape: circle{1}, Color: "red"},
+			"Shape:\n\tCircle\n\t\tRadius:\n\t\t\t1\nColor:\n\tred",
+		},
+		{
+			exportedCanvas{Shape: &rect{2, 3}},
+			"Shape:\n\tRect\n\t\tWidth:\n\t\t\t2\n\t\tHeight:\n\t\t\t3\nColor:\n\t\"\"",
+		},
+		{
+			exportedCanvas{},
+			"Shape:\n\tnil\nColor:\n\t\"\"",
+		},
+		{
+			canvas{Name: "a", Shapes: []shape{circle{2}, &rect{1, 1}}, Any: []interface{}{circle{3}, "Circle"}},
+			"Name:\n\ta\nShapes:\n\tCircle\n\t\tRadius:\n\t\t\t2\n\tRect\n\t\tWidth:\n\t\t\t1\n\t\tHeight:\n\t\t\t1\nAny:\n\tCircle\n\t\tRadius:\n\t\t\t3\n\t\"Circle\"",
+		},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestInterfaceError(t *testing.T) {
+	var c exportedCanvas
+	if err := Unmarshal([]byte("Shape:\n\tSquare\n\t\tSide:\n\t\t\t1"), &c); err == nil {
+		t.Fatal("expect error for an unregistered type name")
+	}
+	var s struct{ S shape }
+	if err := Unmarshal([]byte("S:\n\tCircle\n\t\tRadius:\n\t\t\tx"), &s); err == nil {
+		t.Fatal("expect error for an invalid value of a registered type")
+	}
+	var r struct{ R interface{ Width() float64 } }
+	if err := Unmarshal([]byte("R:\n\tRect"), &r); err == nil {
+		t.Fatal("expect error for a type not implementing the interface")
+	}
+}
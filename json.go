@@ -0,0 +1,39 @@
+package teff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// A json.RawMessage always holds JSON rather than TEFF. It is encoded as a
+// value of its compacted JSON text, which never contains a line break, and nil
+// is encoded as nil. When decoded, the value is kept as is, and an error is
+// returned if it is not a valid JSON.
+func (enc *Encoder) marshalRawMessage(v reflect.Value) (core.Node, error) {
+	if v.IsNil() {
+		return enc.nilNode(), nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, v.Bytes()); err != nil {
+		return core.Node{}, err
+	}
+	return core.Node{Value: buf.String()}, nil
+}
+
+func (dec *Decoder) unmarshalRawMessage(node core.Node, v reflect.Value) error {
+	if dec.isNil(core.List{node}) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if !json.Valid([]byte(node.Value)) {
+		return fmt.Errorf("invalid JSON for json.RawMessage: %s", node.Value)
+	}
+	v.SetBytes([]byte(node.Value))
+	return nil
+}
@@ -0,0 +1,50 @@
+package teff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRawMessage(t *testing.T) {
+	type S struct {
+		Data json.RawMessage
+	}
+	for i, testcase := range []struct {
+		value S
+		text  string
+	}{
+		{S{json.RawMessage(`{"a": [1, 2]}`)}, "Data:\n\t{\"a\":[1,2]}"},
+		{S{json.RawMessage(`"a b"`)}, "Data:\n\t\"a b\""},
+		{S{json.RawMessage(`1`)}, "Data:\n\t1"},
+		{S{}, "Data:\n\tnil"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		var s S
+		if err := Unmarshal(buf, &s); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		buf, err = Marshal(s)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+	}
+	{
+		var raw json.RawMessage
+		if err := Unmarshal([]byte(`{a}`), &raw); err == nil {
+			t.Fatal("expect error for invalid JSON")
+		}
+	}
+	if err := CheckType(reflect.TypeOf(S{})); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package teff
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"h12.io/teff/core"
+)
+
+// SetLocations sets whether the line numbers of the decoded values are
+// recorded, and returned by Locations.
+func (dec *Decoder) SetLocations(record bool) {
+	dec.locations = record
+}
+
+// Locations returns the line numbers starting from 1 of the values of the last
+// decoding, keyed by their paths as Directives. A value under a key is located
+// at the line of the key.
+func (dec *Decoder) Locations() map[string]int {
+	return dec.locationMap
+}
+
+// parseLocations parses the input and records the line of each node. The nodes
+// are parsed in the order of their tokens, which is the pre-order of the tree.
+func (dec *Decoder) parseLocations() (core.List, error) {
+	r, err := dec.reader()
+	if err != nil {
+		return nil, err
+	}
+	lines := &lineRecorder{}
+	scanner := dec.newScanner(io.TeeReader(r, lines))
+	var offsets []int
+	scanner.SetFilter(func(tok core.Token) (core.Token, bool) {
+		if tok.Type == core.LineValue || tok.Type == core.Reference {
+			offsets = append(offsets, tok.Offset)
+		}
+		return tok, true
+	})
+	list, err := core.ParseScanner(scanner)
+	dec.errs = append(dec.errs, scanner.Errors()...)
+	if err != nil {
+		return nil, err
+	}
+	dec.locationMap = make(map[string]int)
+	i := 0
+	walkPaths(list, "", func(node core.Node, path string) {
+		if _, ok := dec.locationMap[pathOrRoot(path)]; !ok {
+			dec.locationMap[pathOrRoot(path)] = lines.line(offsets[i])
+		}
+		i++
+		if _, ok := blockString(node); ok {
+			i += len(node.List)
+		}
+	})
+	return list, nil
+}
+
+// walkPaths visits the nodes of list in pre-order with their paths. A key-value
+// pair has the path of its key, an element of a list of more than one element
+// has its index appended, and a single value shares the path of its parent.
+// The lines of a block string are not visited.
+func walkPaths(list core.List, path string, visit func(node core.Node, path string)) {
+	for i, node := range list {
+		nodePath := path
+		if key, _, ok := splitKeyValue(node); ok {
+			nodePath = joinPath(path, key)
+		} else if len(list) > 1 {
+			nodePath = path + "[" + strconv.Itoa(i) + "]"
+		}
+		visit(node, nodePath)
+		if _, ok := blockString(node); ok {
+			continue
+		}
+		walkPaths(node.List, nodePath, visit)
+	}
+}
+
+// lineRecorder records the offsets of the line breaks written to it.
+type lineRecorder struct {
+	n      int
+	breaks []int
+	cr     bool
+}
+
+func (r *lineRecorder) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch {
+		case b == '\r':
+			r.breaks = append(r.breaks, r.n)
+		case b == '\n' && !r.cr:
+			r.breaks = append(r.breaks, r.n)
+		}
+		r.cr = b == '\r'
+		r.n++
+	}
+	return len(p), nil
+}
+
+func (r *lineRecorder) line(offset int) int {
+	return sort.SearchInts(r.breaks, offset) + 1
+}
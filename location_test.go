@@ -0,0 +1,38 @@
+package teff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocations(t *testing.T) {
+	text := "Name:\n\ta\r\n# comment\nServer:\n\tHost: h\n\tPorts:\n\t\t80\n\n\t\t443\n\tNote:\n\t\t|\n\t\t\tx\n\t\t\ty\nEnd: 1"
+	var v struct {
+		Name   string
+		Server struct {
+			Host  string
+			Ports []int
+			Note  string
+		}
+		End int
+	}
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetLocations(true)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{
+		"Name":            1,
+		"Server":          4,
+		"Server.Host":     5,
+		"Server.Ports":    6,
+		"Server.Ports[0]": 7,
+		"Server.Ports[1]": 9,
+		"Server.Note":     10,
+		"End":             14,
+	}
+	if !reflect.DeepEqual(dec.Locations(), expected) {
+		t.Fatalf("expect %v but got %v", expected, dec.Locations())
+	}
+}
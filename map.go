@@ -0,0 +1,235 @@
+package teff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"h12.io/teff/core"
+)
+
+func (enc *Encoder) marshalMap(v reflect.Value) (core.List, error) {
+	if v.IsNil() {
+		return core.List{enc.nilNode()}, nil
+	}
+	entries, err := enc.mapEntries(v)
+	if err != nil {
+		return nil, err
+	}
+	list := make(core.List, 0, len(entries))
+	for _, e := range entries {
+		node, err := enc.mapEntry(v, e)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, node)
+	}
+	if enc.align {
+		alignValues(list)
+	}
+	return list, nil
+}
+
+// encodeMap writes a top-level map entry by entry, so that only the keys are
+// held in memory rather than the encoding of the whole map.
+func (enc *Encoder) encodeMap(v reflect.Value) error {
+	entries, err := enc.mapEntries(v)
+	if err != nil {
+		return err
+	}
+	// A bufio.Writer is reused by List.Marshal rather than allocated for each
+	// entry.
+	w := bufio.NewWriter(enc.w)
+	defer func(orig io.Writer) { enc.w = orig }(enc.w)
+	enc.w = w
+	if err := enc.writeHeader(len(entries) > 0); err != nil {
+		return err
+	}
+	sep := []byte("\n")
+	if enc.groupSpacing {
+		sep = []byte("\n\n")
+	}
+	for i, e := range entries {
+		node, err := enc.mapEntry(v, e)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := enc.w.Write(sep); err != nil {
+				return err
+			}
+		}
+		if err := (core.List{node}).Marshal(enc.w, enc.prefix, enc.indent); err != nil {
+			return err
+		}
+	}
+	if enc.trailingNewline && len(entries) > 0 {
+		if _, err := enc.w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+type mapEntry struct {
+	key  reflect.Value
+	name string
+}
+
+// less orders int keys numerically and other keys by their encoded names.
+func (e mapEntry) less(o mapEntry) bool {
+	if e.key.Kind() == reflect.Int {
+		return e.key.Int() < o.key.Int()
+	}
+	return e.name+":" < o.name+":"
+}
+
+// mapEntries returns the entries of a map in the encoding order. The entries
+// are ordered before the values are encoded, so that labels are assigned in
+// the output order and are reproducible.
+func (enc *Encoder) mapEntries(v reflect.Value) ([]mapEntry, error) {
+	entries := make([]mapEntry, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		k, err := enc.marshalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, mapEntry{key, k})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].less(entries[j]) })
+	if enc.mapLess != nil {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return enc.mapLess(v.MapIndex(entries[i].key), v.MapIndex(entries[j].key))
+		})
+	}
+	return entries, nil
+}
+
+func (enc *Encoder) mapEntry(v reflect.Value, e mapEntry) (core.Node, error) {
+	value, err := enc.marshalList(v.MapIndex(e.key))
+	if err != nil {
+		return core.Node{}, err
+	}
+	return enc.keyValue(e.name, v.Type().Elem(), value), nil
+}
+
+// SortMapByValue sets a function ordering map entries by their values, e.g. by
+// a numeric field of the values. Entries of equal values are still ordered by
+// their keys, which is the default order of all entries.
+func (enc *Encoder) SortMapByValue(less func(a, b reflect.Value) bool) {
+	enc.mapLess = less
+}
+
+func (dec *Decoder) unmarshalMap(list core.List, v reflect.Value) error {
+	if dec.isNil(list) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	list, err := dec.pairList(list)
+	if err != nil {
+		return err
+	}
+	if !dec.merge {
+		for _, key := range v.MapKeys() {
+			v.SetMapIndex(key, reflect.Value{})
+		}
+	}
+	t := v.Type()
+	for _, node := range list {
+		k, value, ok := splitKeyValue(node)
+		if !ok {
+			return fmt.Errorf("map key should end with colon: %s", node.Value)
+		}
+		key := reflect.New(t.Key()).Elem()
+		if err := dec.unmarshalNode(core.Node{Value: k}, key); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := dec.unmarshalList(value, elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (enc *Encoder) marshalKey(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.String:
+		node, err := enc.marshalNode(v)
+		if err != nil {
+			return "", err
+		}
+		if len(node.List) > 0 {
+			return strconv.Quote(v.String()), nil
+		}
+		return node.Value, nil
+	}
+	return "", fmt.Errorf("unsupported map key type: %v", v.Type())
+}
+
+// keyValue returns the node of a key-value pair. In inline mode, a scalar or
+// nil value of type t is put on the same line as the key, i.e. "key: value",
+// as long as the pair can be split unambiguously.
+func (enc *Encoder) keyValue(key string, t reflect.Type, value core.List) core.Node {
+	if enc.inline && (enc.registry.isScalarType(t) || enc.isNil(value)) &&
+		len(value) == 1 && len(value[0].List) == 0 && len(value[0].Annotations) == 0 &&
+		!value[0].IsReference && value[0].Value != "" && !strings.HasSuffix(value[0].Value, ":") &&
+		!strings.Contains(key, ": ") {
+		return core.Node{Value: key + ": " + value[0].Value}
+	}
+	return core.Node{Value: key + ":", List: value}
+}
+
+func (r *Registry) isScalarType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return r.isLeafType(t)
+}
+
+// splitKeyValue splits a node of a key-value pair in either the block form or
+// the inline form.
+func splitKeyValue(node core.Node) (key string, value core.List, ok bool) {
+	if node.IsReference {
+		return "", nil, false
+	}
+	if strings.HasSuffix(node.Value, ":") {
+		return strings.TrimSuffix(node.Value, ":"), node.List, true
+	}
+	if i := strings.Index(node.Value, ": "); i >= 0 && len(node.List) == 0 {
+		return node.Value[:i], core.List{{Value: strings.TrimLeft(node.Value[i+2:], " ")}}, true
+	}
+	return "", nil, false
+}
+
+// alignValues pads the keys of the inline pairs in a list of key-value pairs,
+// so that their values are aligned in a column.
+func alignValues(list core.List) {
+	width := 0
+	for _, node := range list {
+		if i := strings.Index(node.Value, ": "); i >= 0 && len(node.List) == 0 {
+			if n := utf8.RuneCountInString(node.Value[:i]); n > width {
+				width = n
+			}
+		}
+	}
+	for j, node := range list {
+		if i := strings.Index(node.Value, ": "); i >= 0 && len(node.List) == 0 {
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(node.Value[:i]))
+			list[j].Value = node.Value[:i+2] + pad + node.Value[i+2:]
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package teff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{map[string]int{}, ""},
+		{map[string]int(nil), "nil"},
+		{map[string]int{"b": 2, "a": 1}, "a:\n\t1\nb:\n\t2"},
+		{map[string]string{"a b": "c", "1": "d"}, "\"1\":\n\td\na b:\n\tc"},
+		{map[bool]int{true: 1}, "true:\n\t1"},
+		{map[int]string{10: "c", 2: "b", 1: "a", -3: "d"}, "-3:\n\td\n1:\n\ta\n2:\n\tb\n10:\n\tc"},
+		{map[string]*int{"a": nil, "b": pi(1)}, "a:\n\tnil\nb:\n\t1"},
+		{map[string]interface{}{"a": nil}, "a:\n\tnil"},
+		{map[string][]int{"a": {1, 2}}, "a:\n\t1\n\t2"},
+		{[]map[string]int{{"a": 1}, nil}, "_\n\ta:\n\t\t1\nnil"},
+		{struct{ M map[string]int }{}, "M:\n\tnil"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		if testcase.text == "nil" {
+			continue
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestMapNilValue(t *testing.T) {
+	var m map[string]*int
+	if err := Unmarshal([]byte("a:\n\tnil"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := m["a"]; !ok || p != nil {
+		t.Fatalf("expect a nil entry but got %v", m)
+	}
+}
+
+func pi(i int) *int {
+	return &i
+}
+
+func TestNestedMap(t *testing.T) {
+	type point struct{ X, Y int }
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{map[string]map[string]int{"a": {"x": 1, "y": 2}, "b": {}, "c": nil},
+			"a:\n  x:\n    1\n  y:\n    2\nb:\nc:\n  nil"},
+		{map[string]map[string]point{"a": {"p": {1, 2}}},
+			"a:\n  p:\n    X:\n      1\n    Y:\n      2"},
+	} {
+		buf, err := MarshalIndent(testcase.value, "", "  ")
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestSortMapByValue(t *testing.T) {
+	scores := map[string]int{"a": 2, "b": 3, "c": 1, "d": 3}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetInline(true)
+	enc.SortMapByValue(func(a, b reflect.Value) bool { return a.Int() > b.Int() })
+	if err := enc.Encode(scores); err != nil {
+		t.Fatal(err)
+	}
+	text := "b: 3\nd: 3\na: 2\nc: 1"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	c := &Codec{Inline: true, SortMapByValue: func(a, b reflect.Value) bool { return a.Int() > b.Int() }}
+	buf, err := c.Marshal(scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s from Codec", text, buf)
+	}
+}
+
+func TestEncodeMap(t *testing.T) {
+	m := map[string][]int{"a": {1}, "b": {2, 3}, "c": nil}
+	for _, spacing := range []bool{false, true} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetGroupSpacing(spacing)
+		enc.SetTrailingNewline(true)
+		enc.SetHeader("h")
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		list, err := enc.marshalMap(reflect.ValueOf(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected bytes.Buffer
+		enc.w = &expected
+		if err := enc.writeList(list); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != expected.String() {
+			t.Fatalf("expect \n%s\n    but got \n%s", expected.String(), w.String())
+		}
+	}
+}
+
+func newLargeMap(n int) map[string][]int {
+	m := make(map[string][]int, n)
+	for i := 0; i < n; i++ {
+		m[strconv.Itoa(i)] = []int{i, i + 1, i + 2}
+	}
+	return m
+}
+
+// peakHeapWriter discards the output but samples the live heap every 16KB
+// written, to record its peak above base while encoding.
+type peakHeapWriter struct {
+	base    uint64
+	peak    uint64
+	written int
+	next    int
+}
+
+func newPeakHeapWriter() *peakHeapWriter {
+	return &peakHeapWriter{base: liveHeap()}
+}
+
+func (w *peakHeapWriter) Write(p []byte) (int, error) {
+	if w.written += len(p); w.written >= w.next {
+		w.next = w.written + 16<<10
+		if h := liveHeap(); h > w.base && h-w.base > w.peak {
+			w.peak = h - w.base
+		}
+	}
+	return len(p), nil
+}
+
+func liveHeap() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// TestEncodeMapMemory checks that a top-level map is streamed without holding
+// the encoded tree of all its entries, by comparing the peak live heap with
+// encoding the whole tree first.
+func TestEncodeMapMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measuring the heap is slow")
+	}
+	m := newLargeMap(20000)
+	stream := newPeakHeapWriter()
+	if err := NewEncoder(stream).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	whole := newPeakHeapWriter()
+	enc := NewEncoder(whole)
+	list, err := enc.marshalList(reflect.ValueOf(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.writeList(list); err != nil {
+		t.Fatal(err)
+	}
+	if stream.peak == 0 || stream.peak > whole.peak/2 {
+		t.Fatalf("expect the peak heap of streaming below half of %d bytes but got %d", whole.peak, stream.peak)
+	}
+}
+
+func BenchmarkEncodeMap(b *testing.B) {
+	m := newLargeMap(100000)
+	w := newPeakHeapWriter()
+	if err := NewEncoder(w).Encode(m); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewEncoder(ioutil.Discard).Encode(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(w.peak), "peak-heap-B")
+}
+
+func TestMapOfStructPtr(t *testing.T) {
+	type service struct {
+		Host string
+		Port int
+	}
+	text := "db:\n\tPort:\n\t\t5432\nweb:\n\tHost:\n\t\ta\n\tPort:\n\t\t80"
+	var m map[string]*service
+	if err := Unmarshal([]byte(text), &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]*service{"db": {"", 5432}, "web": {"a", 80}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expect %v but got %v", expected, m)
+	}
+}
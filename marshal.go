@@ -1,12 +1,18 @@
 package teff
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"h12.io/teff/core"
 	"io"
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 func Marshal(v interface{}) ([]byte, error) {
@@ -15,54 +21,670 @@ func Marshal(v interface{}) ([]byte, error) {
 
 func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
 	var w bytes.Buffer
-	err := NewEncoder(&w).marshalIndent(v, prefix, indent)
-	if err != nil {
+	if err := Encode(&w, v, &Options{Prefix: prefix, Indent: indent}); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// MarshalToTree is like Marshal but returns the tree of v rather than the text,
+// which is written by List.Marshal.
+func MarshalToTree(v interface{}) (core.List, error) {
+	return NewEncoder(nil).EncodeTree(v)
+}
+
+// MarshalValue is like Marshal but accepts a reflect.Value directly.
+func MarshalValue(v reflect.Value) ([]byte, error) {
+	var w bytes.Buffer
+	if err := NewEncoder(&w).encodeValue(v); err != nil {
 		return nil, err
 	}
 	return w.Bytes(), nil
 }
 
+// Unmarshal decodes data into the value pointed to by v. An existing value is
+// reused rather than reallocated, but nothing of it is kept: the struct fields
+// absent from data are set to zero, a slice is truncated before the elements
+// are appended, a map is cleared before the entries are added, and a non-nil
+// pointer is decoded into the value it points to.
 func Unmarshal(data []byte, v interface{}) error {
-	if string(data) == "nil" {
+	return Decode(bytes.NewReader(data), v, nil)
+}
+
+// UnmarshalValue is like Unmarshal but accepts a reflect.Value directly, which
+// must be either settable or a non-nil pointer.
+func UnmarshalValue(data []byte, v reflect.Value) error {
+	if !v.IsValid() || !v.CanSet() && (v.Kind() != reflect.Ptr || v.IsNil()) {
+		return fmt.Errorf("teff: UnmarshalValue(unsettable %v)", v)
+	}
+	return NewDecoder(bytes.NewReader(data)).decodeValue(v)
+}
+
+// UnmarshalPartial decodes the first top-level node of data into v and returns
+// the rest of data starting from the next top-level node. io.EOF is returned
+// if there is no node left.
+func UnmarshalPartial(data []byte, v interface{}) (rest []byte, err error) {
+	list, n, err := core.ParseFirst(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, io.EOF
+	}
+	if err := checkPointer(v); err != nil {
+		return nil, err
+	}
+	if err := NewDecoder(nil).unmarshalNode(list[0], reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return data[n:], nil
+}
+
+// UnmarshalPath decodes into v only the value at a dotted path of keys in data,
+// e.g. server.tls.cert.
+func UnmarshalPath(data []byte, path string, v interface{}) error {
+	if err := checkPointer(v); err != nil {
+		return err
+	}
+	list, err := core.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, key := range strings.Split(path, ".") {
+		if list, err = lookupKey(list, key); err != nil {
+			return fmt.Errorf("path %s: %v", path, err)
+		}
+	}
+	dec := NewDecoder(nil)
+	if dec.isNil(list) {
 		return nil
 	}
-	list, err := core.Parse(bytes.NewBuffer(data))
+	return dec.unmarshalList(list, reflect.ValueOf(v))
+}
+
+func lookupKey(list core.List, key string) (core.List, error) {
+	for _, node := range list {
+		if k, value, ok := splitKeyValue(node); ok && unquoteKey(k) == key {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("key %s not found", key)
+}
+
+type Decoder struct {
+	r            io.Reader
+	strict       bool
+	lenient      bool
+	expander     func(string) string
+	recover      bool
+	errs         []error
+	fieldNamer   func(string) string
+	fold         bool
+	duration     func(string) (time.Duration, error)
+	merge        bool
+	wrap         bool
+	maxString    int
+	maxIndent    int
+	enumZero     bool
+	enumFold     bool
+	nilToken     string
+	pairs        bool
+	directives   bool
+	directiveMap map[string][]Directive
+	locations    bool
+	locationMap  map[string]int
+	labels       map[string]reflect.Value
+	transform    func(io.Reader) io.Reader
+	fallback     func([]byte, interface{}) error
+	zero         bool
+	jsonTags     bool
+	skipRedacted bool
+	decompress   bool
+	byteEncoding ByteEncoding
+	trues        []string
+	falses       []string
+	registry     *Registry
+	list         core.List // parsed element of DecodeEach
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetStrict sets whether the literal of a scalar must match the type of its
+// destination exactly. In strict mode, a bool must be either true or false, an
+// int must be an unquoted integer, and a string must be either quoted or not
+// look like a literal of other types. Otherwise, quoted bools and ints are
+// accepted, and so are all the bool spellings accepted by strconv.ParseBool.
+func (dec *Decoder) SetStrict(strict bool) {
+	dec.strict = strict
+}
+
+// SetLenient sets whether more scalars are coerced to the type of their
+// destination where unambiguous, in addition to those accepted when not in
+// strict mode: an int accepts a float of an integral value, e.g. 5.0 or 1e3,
+// and a bool accepts yes, no, on, off, y and n in any case. It is ignored in
+// strict mode.
+func (dec *Decoder) SetLenient(lenient bool) {
+	dec.lenient = lenient
+}
+
+// SetRecover sets whether the lines that fail to be scanned are skipped rather
+// than stopping the decoding. The errors of the skipped lines are returned by
+// Errors after decoding.
+func (dec *Decoder) SetRecover(recover bool) {
+	dec.recover = recover
+}
+
+// SetZeroBeforeDecode sets whether the destination is set to zero before
+// decoding, so that nothing of an existing value is reused, e.g. the values
+// pointed to by its pointers are not overwritten but newly allocated. It
+// overrides SetMerge.
+func (dec *Decoder) SetZeroBeforeDecode(zero bool) {
+	dec.zero = zero
+}
+
+// SetTransform sets a function wrapping the input, e.g. to transcode a legacy
+// encoding to UTF-8 with transform.NewReader of golang.org/x/text:
+//
+//	dec.SetTransform(func(r io.Reader) io.Reader {
+//		return transform.NewReader(r, charmap.ISO8859_1.NewDecoder())
+//	})
+//
+// By default, the input must be valid UTF-8.
+func (dec *Decoder) SetTransform(transform func(io.Reader) io.Reader) {
+	dec.transform = transform
+}
+
+// SetDecompress sets whether a gzip compressed input is decompressed, so that
+// both compressed and uncompressed inputs are accepted. A compressed input is
+// detected by the gzip header, which cannot start a valid uncompressed input.
+func (dec *Decoder) SetDecompress(decompress bool) {
+	dec.decompress = decompress
+}
+
+// decompress returns a reader of the decompressed r if r starts with a gzip
+// header, or a reader of r as is otherwise.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// Errors returns the errors of the lines skipped in recovery mode.
+func (dec *Decoder) Errors() []error {
+	return dec.errs
+}
+
+// SetMerge sets whether decoding merges the input into an existing value for
+// layered or partial updates, rather than following the reuse contract of
+// Unmarshal: the struct fields absent from the input keep their values, map
+// entries are added to an existing map, and a non-nil pointer is filled in
+// place. A slice is still replaced.
+func (dec *Decoder) SetMerge(merge bool) {
+	dec.merge = merge
+}
+
+// SetWrapScalars sets whether a scalar is decoded as a one-element slice where
+// a composite element "_" of a nested slice is expected. A scalar value of a
+// struct field or a map entry is always accepted as a one-element slice, since
+// it is the same list as one with a single element.
+func (dec *Decoder) SetWrapScalars(wrap bool) {
+	dec.wrap = wrap
+}
+
+// SetMaxStringLength sets the maximum length in bytes of a decoded string, so
+// that an enormous string value is rejected. Zero, the default, means no limit.
+func (dec *Decoder) SetMaxStringLength(n int) {
+	dec.maxString = n
+}
+
+// SetMaxIndent sets the maximum number of characters of an indent, so that a
+// pathologically long indent fails early. Zero, the default, means no limit.
+func (dec *Decoder) SetMaxIndent(n int) {
+	dec.maxIndent = n
+}
+
+// SetNilToken sets a literal accepted as nil in addition to nil, e.g. null or
+// ~, for interoperability with other systems.
+func (dec *Decoder) SetNilToken(token string) {
+	dec.nilToken = token
+}
+
+// SetCaseInsensitive sets whether a key matches a struct field name case
+// insensitively when there is no exact match.
+func (dec *Decoder) SetCaseInsensitive(fold bool) {
+	dec.fold = fold
+}
+
+// SetFieldNamer sets the function mapping struct field names without a tag to
+// encoded names, it should match the one used by the Encoder.
+func (dec *Decoder) SetFieldNamer(namer func(string) string) {
+	dec.fieldNamer = namer
+}
+
+// SetJSONTags sets whether a struct field without a teff tag is named by its
+// json tag, if any, and a field tagged json:"-" is ignored, e.g. to migrate
+// from JSON without retagging the structs. It should match the Encoder.
+func (dec *Decoder) SetJSONTags(json bool) {
+	dec.jsonTags = json
+}
+
+func (dec *Decoder) Decode(v interface{}) error {
+	if v == nil {
+		return dec.decodeValue(reflect.Value{})
+	}
+	if err := checkPointer(v); err != nil {
+		return err
+	}
+	return dec.decodeValue(reflect.ValueOf(v))
+}
+
+// checkPointer returns an error if v is not a non-nil pointer.
+func checkPointer(v interface{}) error {
+	if v == nil {
+		return fmt.Errorf("teff: Unmarshal(nil)")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return fmt.Errorf("teff: Unmarshal(non-pointer %v)", rv.Type())
+	}
+	if rv.IsNil() {
+		return fmt.Errorf("teff: Unmarshal(nil %v)", rv.Type())
+	}
+	return nil
+}
+
+func (dec *Decoder) decodeValue(v reflect.Value) error {
+	dec.labels = nil
+	if err := checkBoolTokens(dec.trues, dec.falses, dec.nilToken); err != nil {
+		return err
+	}
+	list, err := dec.parse()
 	if err != nil {
 		return err
 	}
-	return unmarshalList(list, reflect.ValueOf(v))
+	if dec.directives {
+		dec.directiveMap = nil
+		dec.collectDirectives(list)
+	}
+	if dec.zero && v.IsValid() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+	if dec.isNil(list) {
+		return nil
+	}
+	if !v.IsValid() {
+		return fmt.Errorf("teff: Unmarshal(nil)")
+	}
+	return dec.unmarshalList(list, v)
+}
+
+func (dec *Decoder) parse() (core.List, error) {
+	if dec.list != nil {
+		return dec.list, nil
+	}
+	if dec.locations {
+		return dec.parseLocations()
+	}
+	r, err := dec.reader()
+	if err != nil {
+		return nil, err
+	}
+	scanner := dec.newScanner(r)
+	list, err := core.ParseScanner(scanner)
+	dec.errs = append(dec.errs, scanner.Errors()...)
+	return list, err
+}
+
+func (dec *Decoder) newScanner(r io.Reader) *core.Scanner {
+	scanner := core.NewScanner(bufio.NewReader(r))
+	scanner.SetRecover(dec.recover)
+	scanner.SetMaxIndent(dec.maxIndent)
+	return scanner
+}
+
+func (dec *Decoder) reader() (io.Reader, error) {
+	r := dec.r
+	if dec.decompress {
+		var err error
+		if r, err = decompress(r); err != nil {
+			return nil, err
+		}
+	}
+	if dec.transform != nil {
+		return dec.transform(r), nil
+	}
+	return r, nil
 }
 
 type Encoder struct {
-	w io.Writer
+	w               io.Writer
+	prefix          string
+	indent          string
+	groupSpacing    bool
+	fieldNamer      func(string) string
+	inline          bool
+	align           bool
+	trailingNewline bool
+	drainChannels   bool
+	stringer        bool
+	errorMessages   bool
+	jsonTags        bool
+	byteEncoding    ByteEncoding
+	trues           []string
+	falses          []string
+	registry        *Registry
+	annotator       func(index int, elem interface{}) string
+	sortFields      bool
+	escapeNonASCII  bool
+	foldWidth       int
+	numericBool     bool
+	canonical       bool
+	floatPrec       int
+	utc             bool
+	header          string
+	mapLess         func(a, b reflect.Value) bool
+	nilToken        string
+	selectField     func(name string) bool
+	refs            *refRegister
 }
 
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+	return &Encoder{w: w, indent: "\t", floatPrec: -1}
 }
 
-func (enc *Encoder) Encode(v interface{}) error {
+// SetIndent sets the prefix of each line and the string of each indent level,
+// the default is no prefix and a tab.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.prefix, enc.indent = prefix, indent
+}
+
+// SetGroupSpacing sets whether a blank line is inserted between top-level
+// nodes.
+func (enc *Encoder) SetGroupSpacing(spacing bool) {
+	enc.groupSpacing = spacing
+}
+
+// SetNilToken sets the literal of nil, e.g. null or ~, for interoperability
+// with other systems. The default is nil.
+func (enc *Encoder) SetNilToken(token string) {
+	enc.nilToken = token
+}
+
+// SetHeader sets a comment written before the data, e.g. "generated by X; do
+// not edit". Each line of the header is written as an annotation after "# ".
+func (enc *Encoder) SetHeader(header string) {
+	enc.header = header
+}
+
+// SetFieldNamer sets the function mapping struct field names without a tag to
+// encoded names, e.g. from CamelCase to snake_case.
+func (enc *Encoder) SetFieldNamer(namer func(string) string) {
+	enc.fieldNamer = namer
+}
+
+// SetJSONTags sets whether a struct field without a teff tag is named by its
+// json tag, if any, and a field tagged json:"-" is ignored.
+func (enc *Encoder) SetJSONTags(json bool) {
+	enc.jsonTags = json
+}
+
+// SetTrailingNewline sets whether a newline is written after the last line of
+// a non-empty output. By default, the output ends without a newline.
+func (enc *Encoder) SetTrailingNewline(newline bool) {
+	enc.trailingNewline = newline
+}
+
+// SetStringerFallback sets whether a value of an otherwise unsupported type
+// that implements fmt.Stringer is encoded as the string returned by its String
+// method. It is lossy and meant for diagnostic dumps: such a value cannot be
+// decoded back.
+func (enc *Encoder) SetStringerFallback(fallback bool) {
+	enc.stringer = fallback
+}
+
+func (enc *Encoder) marshalStringer(v reflect.Value) (core.Node, bool) {
+	if !enc.stringer || !v.CanInterface() {
+		return core.Node{}, false
+	}
+	s, ok := v.Interface().(fmt.Stringer)
+	if !ok && v.CanAddr() {
+		s, ok = v.Addr().Interface().(fmt.Stringer)
+	}
+	if !ok {
+		return core.Node{}, false
+	}
+	node, err := enc.marshalNode(reflect.ValueOf(s.String()))
+	return node, err == nil
+}
+
+// SetElementAnnotator sets a function returning the annotation written above
+// each element of a slice, given the index and the element. Nothing is written
+// for an empty annotation, and each line of the annotation is written as a
+// separate annotation after "#". The annotations are ignored by the Decoder.
+func (enc *Encoder) SetElementAnnotator(annotate func(index int, elem interface{}) string) {
+	enc.annotator = annotate
+}
+
+// SetCanonical sets whether the encoding is canonical, i.e. equal values are
+// always encoded the same. In canonical mode, struct fields are sorted as by
+// SetSortFields, and a float of NaN or infinity is an error because it has no
+// agreed canonical text.
+func (enc *Encoder) SetCanonical(canonical bool) {
+	enc.canonical = canonical
+}
+
+// SetSortFields sets whether struct fields are encoded in the order of their
+// encoded names rather than the declaration order.
+func (enc *Encoder) SetSortFields(sort bool) {
+	enc.sortFields = sort
+}
+
+// SetEscapeNonASCII sets whether strings containing non-ASCII characters are
+// quoted with only ASCII characters.
+func (enc *Encoder) SetEscapeNonASCII(escape bool) {
+	enc.escapeNonASCII = escape
+}
+
+// SetNumericBool sets whether a bool is encoded as 1 or 0 rather than true or
+// false. The numbers are decoded as bools unless in strict mode.
+func (enc *Encoder) SetNumericBool(numeric bool) {
+	enc.numericBool = numeric
+}
+
+// SetBoolTokens sets the words of true and false, e.g. localized words, so that
+// a bool is encoded as the first word of its set. Empty sets restore true and
+// false. SetNumericBool takes precedence. A string equal to a word is quoted.
+// Encode returns an error if only one set is empty, a word is in both, or a
+// word is not a raw string or is a literal such as nil or a number.
+func (enc *Encoder) SetBoolTokens(trues, falses []string) {
+	enc.trues, enc.falses = trues, falses
+}
+
+// SetBoolTokens sets the words of true and false, so that a bool is decoded
+// only from the words, quoted or not unless in strict mode, in place of the
+// default spellings. Empty sets restore the default. Decode returns an error as
+// Encode does for invalid words.
+func (dec *Decoder) SetBoolTokens(trues, falses []string) {
+	dec.trues, dec.falses = trues, falses
+}
+
+func checkBoolTokens(trues, falses []string, nilToken string) error {
+	if (len(trues) == 0) != (len(falses) == 0) {
+		return fmt.Errorf("bool tokens of only true or false")
+	}
+	for _, tokens := range [][]string{trues, falses} {
+		for _, s := range tokens {
+			if !isRawString(s) || isLiteral(s) || s == "_" || s == nilToken {
+				return fmt.Errorf("invalid bool token %q", s)
+			}
+		}
+	}
+	for _, t := range trues {
+		for _, f := range falses {
+			if t == f {
+				return fmt.Errorf("bool token %s as both true and false", t)
+			}
+		}
+	}
 	return nil
 }
 
-func (enc *Encoder) marshalIndent(v interface{}, prefix, indent string) error {
+// boolToken returns the bool of the word s in the bool tokens, ok is false if
+// s is not a word of the tokens.
+func boolToken(s string, trues, falses []string) (b, ok bool) {
+	for _, t := range trues {
+		if s == t {
+			return true, true
+		}
+	}
+	for _, f := range falses {
+		if s == f {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// SetFoldWidth sets the width beyond which a single-line string is encoded as a
+// folded block string broken at spaces. Zero, the default, keeps it in a line.
+func (enc *Encoder) SetFoldWidth(width int) {
+	enc.foldWidth = width
+}
+
+// SetInline sets whether a scalar value of a struct field or a map entry is
+// encoded on the same line as its key, e.g. "Port: 80", rather than in an
+// indented block. Composite values are always encoded in indented blocks. The
+// Decoder accepts both forms.
+func (enc *Encoder) SetInline(inline bool) {
+	enc.inline = inline
+}
+
+// SetAlignValues sets whether the inline values of a block are padded with
+// spaces to be aligned in a column, e.g.
+//
+//	Name:    a
+//	Port:    80
+//	Enabled: true
+//
+// It only takes effect with SetInline.
+func (enc *Encoder) SetAlignValues(align bool) {
+	enc.align = align
+}
+
+// Reset makes the Encoder write to w, so that it can be reused, e.g. from a
+// sync.Pool. The options are kept and the state of the last encoding is
+// cleared.
+func (enc *Encoder) Reset(w io.Writer) {
+	enc.w = w
+	enc.refs = nil
+}
+
+func (enc *Encoder) Encode(v interface{}) error {
+	return enc.encodeValue(reflect.ValueOf(v))
+}
+
+// EncodeTree returns the tree of v that Encode writes, except the header, e.g.
+// to inspect or transform it before writing it by List.Marshal.
+func (enc *Encoder) EncodeTree(v interface{}) (core.List, error) {
+	if err := checkBoolTokens(enc.trues, enc.falses, enc.nilToken); err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return core.List{enc.nilNode()}, nil
+	}
+	enc.refs = newRefRegister(rv, enc.registry)
+	return enc.marshalList(rv)
+}
+
+func (enc *Encoder) encodeValue(v reflect.Value) error {
+	if err := checkBoolTokens(enc.trues, enc.falses, enc.nilToken); err != nil {
+		return err
+	}
 	var list core.List
 	var err error
-	if v == nil {
-		list = core.List{core.Node{Value: "nil"}}
+	if !v.IsValid() {
+		list = core.List{enc.nilNode()}
 	} else {
-		list, err = marshalList(reflect.ValueOf(v))
+		enc.refs = newRefRegister(v, enc.registry)
+		if m := indirect(v); m.Kind() == reflect.Map && !m.IsNil() && !enc.refs.shared(v) && !enc.align && !enc.hasCustom(v) {
+			return enc.encodeMap(m)
+		}
+		list, err = enc.marshalList(v)
 		if err != nil {
 			return err
 		}
 	}
-	return list.Marshal(enc.w, prefix, indent)
+	return enc.writeList(list)
 }
 
-func marshalList(v reflect.Value) (core.List, error) {
+func (enc *Encoder) writeList(list core.List) error {
+	if err := enc.writeHeader(len(list) > 0); err != nil {
+		return err
+	}
+	if err := enc.writeNodes(list); err != nil {
+		return err
+	}
+	if enc.trailingNewline && len(list) > 0 {
+		_, err := enc.w.Write([]byte("\n"))
+		return err
+	}
+	return nil
+}
+
+func (enc *Encoder) writeHeader(more bool) error {
+	if enc.header == "" {
+		return nil
+	}
+	var w bytes.Buffer
+	for i, line := range strings.Split(enc.header, "\n") {
+		if i > 0 {
+			w.WriteByte('\n')
+		}
+		w.WriteString(enc.prefix + "# " + line)
+	}
+	if more {
+		w.WriteByte('\n')
+	}
+	_, err := enc.w.Write(w.Bytes())
+	return err
+}
+
+func (enc *Encoder) writeNodes(list core.List) error {
+	if !enc.groupSpacing {
+		return list.Marshal(enc.w, enc.prefix, enc.indent)
+	}
+	for i := range list {
+		if i > 0 {
+			if _, err := enc.w.Write([]byte("\n\n")); err != nil {
+				return err
+			}
+		}
+		if err := list[i:i+1].Marshal(enc.w, enc.prefix, enc.indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (enc *Encoder) marshalList(v reflect.Value) (core.List, error) {
+	if enc.registry.isLeafType(v.Type()) {
+		node, err := enc.marshalNode(v)
+		if err != nil {
+			return nil, err
+		}
+		return core.List{node}, nil
+	}
+	if list, ok, err := enc.marshalCustom(v); ok {
+		return list, err
+	}
 	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
-		node, err := marshalNode(v)
+	case reflect.Bool, reflect.Int, reflect.Float32, reflect.Float64, reflect.String:
+		node, err := enc.marshalNode(v)
 		if err != nil {
 			return nil, err
 		}
@@ -70,121 +692,484 @@ func marshalList(v reflect.Value) (core.List, error) {
 	case reflect.Slice:
 		list := make(core.List, v.Len())
 		for i := 0; i < v.Len(); i++ {
-			node, err := marshalNode(v.Index(i))
+			node, err := enc.marshalNode(v.Index(i))
 			if err != nil {
 				return nil, err
 			}
+			if enc.annotator != nil {
+				if a := enc.annotator(i, v.Index(i).Interface()); a != "" {
+					node.Annotations = append(strings.Split(a, "\n"), node.Annotations...)
+				}
+			}
 			list[i] = node
 		}
 		return list, nil
+	case reflect.Struct:
+		return enc.marshalStruct(v)
+	case reflect.Map:
+		return enc.marshalMap(v)
+	case reflect.Chan:
+		return enc.marshalChan(v)
 	case reflect.Ptr:
-		return marshalList(indirect(v))
+		if v.IsNil() {
+			return core.List{enc.nilNode()}, nil
+		}
+		if enc.refs.shared(v) {
+			node, err := enc.marshalPtr(v)
+			return core.List{node}, err
+		}
+		return enc.marshalList(indirect(v))
+	case reflect.Interface:
+		if v.IsNil() {
+			return core.List{enc.nilNode()}, nil
+		}
+		if node, ok, err := enc.marshalTyped(v.Elem()); ok {
+			return core.List{node}, err
+		}
+		if isInferredType(v.Elem().Type()) {
+			return enc.marshalList(v.Elem())
+		}
+	}
+	if node, ok := enc.marshalStringer(v); ok {
+		return core.List{node}, nil
 	}
 	return nil, fmt.Errorf("marshal unsupported")
 }
 
-func unmarshalList(list core.List, v reflect.Value) error {
+func (dec *Decoder) unmarshalList(list core.List, v reflect.Value) error {
+	if dec.registry.isLeafType(v.Type()) {
+		return dec.unmarshalScalar(list, v)
+	}
+	if ok, err := dec.unmarshalCustom(list, v); ok {
+		return err
+	}
 	switch v.Type().Kind() {
-	case reflect.Int, reflect.String:
-		return unmarshalNode(list[0], v)
+	case reflect.Bool, reflect.Int, reflect.Float32, reflect.Float64, reflect.String:
+		return dec.unmarshalScalar(list, v)
 	case reflect.Slice:
+		if !v.IsNil() {
+			v.SetLen(0)
+		}
 		for i, node := range list {
 			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
 			elem := v.Index(i)
-			if err := unmarshalNode(node, elem); err != nil {
+			if err := dec.unmarshalNode(node, elem); err != nil {
 				return err
 			}
 		}
 		return nil
+	case reflect.Struct:
+		return dec.unmarshalStruct(list, v)
+	case reflect.Map:
+		return dec.unmarshalMap(list, v)
+	case reflect.Chan:
+		return dec.unmarshalChan(list, v)
 	case reflect.Ptr:
-		return unmarshalList(list, allocIndirect(v))
+		if dec.isNil(list) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if isLabelled(list) {
+			return dec.unmarshalPtr(list[0], v)
+		}
+		return dec.unmarshalList(list, allocIndirect(v))
+	case reflect.Interface:
+		if dec.isNil(list) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if len(list) == 1 {
+			if ok, err := dec.unmarshalTyped(list[0], v); ok {
+				return err
+			}
+		}
+		if v.NumMethod() == 0 {
+			i, err := dec.inferList(list)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&i).Elem())
+			return nil
+		}
 	}
-	return fmt.Errorf("unmarshal unsupported")
+	return dec.unmarshalFallback(list, v)
 }
 
-func marshalNode(v reflect.Value) (core.Node, error) {
+// unmarshalScalar decodes a list of exactly one node, e.g. an empty string must
+// be quoted rather than omitted.
+func (dec *Decoder) unmarshalScalar(list core.List, v reflect.Value) error {
+	if len(list) != 1 {
+		return fmt.Errorf("expect a single value of %v but got %d", v.Type(), len(list))
+	}
+	return dec.unmarshalNode(list[0], v)
+}
+
+func (enc *Encoder) marshalNode(v reflect.Value) (core.Node, error) {
+	switch v.Type() {
+	case rawMessageType:
+		return enc.marshalRawMessage(v)
+	case timeType:
+		return enc.marshalTime(v, time.RFC3339Nano)
+	case durationType:
+		return marshalDuration(v)
+	}
+	if enc.registry.isTuple(v.Type()) {
+		return enc.marshalTuple(v)
+	}
+	if node, ok, err := enc.marshalCustomNode(v); ok {
+		return node, err
+	}
+	if isBytesType(v.Type()) {
+		return enc.marshalBytes(v)
+	}
 	switch v.Type().Kind() {
-	case reflect.Int:
+	case reflect.Bool:
+		if enc.numericBool {
+			if v.Bool() {
+				return core.Node{Value: "1"}, nil
+			}
+			return core.Node{Value: "0"}, nil
+		}
+		if len(enc.trues) > 0 {
+			if v.Bool() {
+				return core.Node{Value: enc.trues[0]}, nil
+			}
+			return core.Node{Value: enc.falses[0]}, nil
+		}
 		return core.Node{Value: fmt.Sprint(v.Interface())}, nil
+	case reflect.Int:
+		if e, ok := enc.registry.lookupEnum(v.Type()); ok {
+			if name, ok := e.names[v.Int()]; ok {
+				return core.Node{Value: name}, nil
+			}
+		}
+		return core.Node{Value: strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return enc.marshalFloat(v)
 	case reflect.String:
 		s := v.Interface().(string)
-		if !strconv.CanBackquote(s) {
+		if enc.escapeNonASCII && !isASCII(s) {
+			return core.Node{Value: strconv.QuoteToASCII(s)}, nil
+		}
+		if node, ok := marshalBlock(s); ok {
+			return node, nil
+		}
+		if enc.foldWidth > 0 {
+			if node, ok := marshalFold(s, enc.foldWidth); ok {
+				return node, nil
+			}
+		}
+		if _, ok := boolToken(s, enc.trues, enc.falses); ok || !isRawString(s) || isLiteral(s) || s == enc.nilToken {
 			s = strconv.Quote(s)
 		}
 		return core.Node{Value: s}, nil
+	case reflect.Slice:
+		list, err := enc.marshalList(v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	case reflect.Struct:
+		list, err := enc.marshalStruct(v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return enc.nilNode(), nil
+		}
+		list, err := enc.marshalMap(v)
+		if err != nil {
+			return core.Node{}, err
+		}
+		return core.Node{Value: "_", List: list}, nil
+	case reflect.Chan:
+		list, err := enc.marshalChan(v)
+		if err != nil || enc.isNil(list) {
+			return enc.nilNode(), err
+		}
+		return core.Node{Value: "_", List: list}, nil
 	case reflect.Ptr:
-		return marshalNode(v.Elem())
+		if v.IsNil() {
+			return enc.nilNode(), nil
+		}
+		return enc.marshalPtr(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			return enc.nilNode(), nil
+		}
+		if node, ok, err := enc.marshalTyped(v.Elem()); ok {
+			return node, err
+		}
+		if isInferredType(v.Elem().Type()) {
+			return enc.marshalNode(v.Elem())
+		}
+	}
+	if node, ok := enc.marshalStringer(v); ok {
+		return node, nil
 	}
 	return core.Node{}, fmt.Errorf("marshal unsupported")
 
 }
 
-func unmarshalNode(node core.Node, v reflect.Value) error {
+func (dec *Decoder) unmarshalNode(node core.Node, v reflect.Value) error {
+	switch v.Type() {
+	case rawMessageType:
+		return dec.unmarshalRawMessage(node, v)
+	case timeType:
+		return unmarshalTime(node, v, time.RFC3339Nano)
+	case durationType:
+		return dec.unmarshalDuration(node, v)
+	}
+	if dec.registry.isTuple(v.Type()) {
+		return dec.unmarshalTuple(node, v)
+	}
+	if node.IsReference && v.Kind() != reflect.Ptr {
+		return fmt.Errorf("reference ^%s to non-pointer %v", node.Value, v.Type())
+	}
+	if ok, err := dec.unmarshalCustomNode(node, v); ok {
+		return err
+	}
+	if isBytesType(v.Type()) {
+		return dec.unmarshalBytes(node, v)
+	}
 	switch v.Type().Kind() {
+	case reflect.Bool:
+		b, err := dec.parseBool(node.Value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
 	case reflect.Int:
-		i, err := strconv.Atoi(node.Value)
+		if e, ok := dec.registry.lookupEnum(v.Type()); ok {
+			return dec.unmarshalEnum(e, node, v)
+		}
+		i, err := dec.parseInt(node.Value)
 		if err != nil {
 			return err
 		}
 		v.SetInt(int64(i))
 		return nil
-	case reflect.String:
-		s, err := strconv.Unquote(node.Value)
+	case reflect.Float32, reflect.Float64:
+		f, err := dec.parseFloat(node.Value, v.Type().Bits())
 		if err != nil {
-			s = node.Value
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, ok := blockString(node)
+		if !ok {
+			var err error
+			if s, err = dec.parseString(node.Value); err != nil {
+				return err
+			}
+		}
+		if dec.expander != nil {
+			s = expand(s, dec.expander)
+		}
+		if dec.maxString > 0 && len(s) > dec.maxString {
+			return fmt.Errorf("string of %d bytes exceeds the maximum length %d", len(s), dec.maxString)
 		}
 		v.SetString(s)
 		return nil
+	case reflect.Slice:
+		if dec.isNil(core.List{node}) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if node.Value != "_" {
+			if !dec.wrap {
+				return fmt.Errorf("expect a composite value of %v but got %s", v.Type(), node.Value)
+			}
+			return dec.unmarshalList(core.List{node}, v)
+		}
+		return dec.unmarshalList(node.List, v)
+	case reflect.Struct:
+		return dec.unmarshalStruct(node.List, v)
+	case reflect.Map:
+		if dec.isNil(core.List{node}) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return dec.unmarshalMap(node.List, v)
+	case reflect.Chan:
+		if dec.isNil(core.List{node}) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return dec.unmarshalChan(node.List, v)
 	case reflect.Ptr:
-		return unmarshalNode(node, allocIndirect(v))
+		if dec.isNil(core.List{node}) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return dec.unmarshalPtr(node, v)
+	case reflect.Interface:
+		if dec.isNil(core.List{node}) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if ok, err := dec.unmarshalTyped(node, v); ok {
+			return err
+		}
+		if v.NumMethod() == 0 {
+			i, err := dec.inferNode(node)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&i).Elem())
+			return nil
+		}
 	}
-	return fmt.Errorf("unmarshal unsupported")
+	return dec.unmarshalFallback(customList(node), v)
 }
 
-func indirect(v reflect.Value) reflect.Value {
-	for v.Type().Kind() == reflect.Ptr && !v.IsNil() {
-		v = reflect.Indirect(v)
+func (dec *Decoder) parseBool(s string) (bool, error) {
+	if len(dec.trues) > 0 {
+		if !dec.strict {
+			s = unquote(s)
+		}
+		if b, ok := boolToken(s, dec.trues, dec.falses); ok {
+			return b, nil
+		}
+		return false, fmt.Errorf("%s is not a bool token", s)
 	}
-	return v
+	if dec.strict {
+		switch s {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return false, fmt.Errorf("strict mode: %s is not a bool", s)
+	}
+	s = unquote(s)
+	if dec.lenient {
+		switch strings.ToLower(s) {
+		case "yes", "y", "on":
+			return true, nil
+		case "no", "n", "off":
+			return false, nil
+		}
+	}
+	return strconv.ParseBool(s)
 }
 
-func allocIndirect(v reflect.Value) reflect.Value {
-	for v.Type().Kind() == reflect.Ptr {
-		if v.IsNil() {
-			v.Set(reflect.New(v.Type().Elem()))
+func (dec *Decoder) parseInt(s string) (int, error) {
+	if !dec.strict {
+		s = unquote(s)
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil && dec.lenient && !dec.strict && isNumber(s) {
+		// Only the integers exactly representable by a float64 are accepted.
+		if f, ferr := strconv.ParseFloat(s, 64); ferr == nil && f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+			return int(f), nil
 		}
-		v = reflect.Indirect(v)
 	}
-	return v
+	return i, err
+}
+
+// parseString decodes a string value by the rules below in order:
+//  1. A value starting with a double quote or a backquote is unquoted as a Go
+//     string literal. If it is not a valid literal, it is an error in strict
+//     mode, otherwise it is kept as is.
+//  2. A value that looks like a literal of another type, e.g. 123 or true, is
+//     an error in strict mode, otherwise it is kept as is.
+//  3. Any other value is kept as is, including inner spaces and single quotes.
+//
+// A block string is handled before these rules.
+func (dec *Decoder) parseString(s string) (string, error) {
+	if s != "" && (s[0] == '"' || s[0] == '`') {
+		u, err := strconv.Unquote(s)
+		if err == nil {
+			return u, nil
+		}
+		if dec.strict {
+			return "", fmt.Errorf("strict mode: %s is not a valid quoted string", s)
+		}
+		return s, nil
+	}
+	if dec.strict && isLiteral(s) {
+		return "", fmt.Errorf("strict mode: %s is not a string", s)
+	}
+	return s, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
 }
 
-type nodeRegistry struct {
-	node  *core.Node
-	label int
+// isLiteral returns true if s is a literal of a non-string type, so that a
+// string of the same content must be quoted.
+func isLiteral(s string) bool {
+	switch s {
+	case "nil", "true", "false":
+		return true
+	}
+	if _, err := strconv.Atoi(s); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil || err.(*strconv.NumError).Err == strconv.ErrRange
+}
+
+// isLeafType returns true if t has a dedicated encoding as a single value.
+func (r *Registry) isLeafType(t reflect.Type) bool {
+	return t == rawMessageType || t == timeType || t == durationType || r.isTuple(t) || isBytesType(t)
+}
+
+// isNilValue returns true if list is a single nil literal, i.e. nil or the
+// custom nil token.
+func isNilValue(list core.List, token string) bool {
+	if len(list) != 1 || list[0].IsReference || len(list[0].List) > 0 {
+		return false
+	}
+	return list[0].Value == "nil" || token != "" && list[0].Value == token
+}
+
+func (enc *Encoder) isNil(list core.List) bool {
+	return isNilValue(list, enc.nilToken)
+}
+
+func (dec *Decoder) isNil(list core.List) bool {
+	return isNilValue(list, dec.nilToken)
 }
 
-type refRegister struct {
-	m      map[uintptr]nodeRegistry
-	serial int
+func (enc *Encoder) nilNode() core.Node {
+	if enc.nilToken != "" {
+		return core.Node{Value: enc.nilToken}
+	}
+	return core.Node{Value: "nil"}
 }
 
-func newRefRegister() *refRegister {
-	return &refRegister{
-		m:      make(map[uintptr]nodeRegistry),
-		serial: 1,
+func indirect(v reflect.Value) reflect.Value {
+	for v.Type().Kind() == reflect.Ptr && !v.IsNil() {
+		v = reflect.Indirect(v)
 	}
+	return v
 }
 
-func (r *refRegister) register(p uintptr, node *core.Node) int {
-	if reg, ok := r.m[p]; ok {
-		if reg.label == 0 {
-			reg.label = r.serial
-			reg.node.Annotations = append(reg.node.Annotations, "# ^"+strconv.Itoa(reg.label))
-			r.serial++
-			r.m[p] = reg
+func allocIndirect(v reflect.Value) reflect.Value {
+	for v.Type().Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
 		}
-		return reg.label
+		v = reflect.Indirect(v)
 	}
-	r.m[p] = nodeRegistry{node, 0}
-	return 0
+	return v
 }
@@ -1,9 +1,17 @@
 package teff
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"h12.io/teff/core"
 )
 
 func TestMarshal(t *testing.T) {
@@ -13,11 +21,20 @@ func TestMarshal(t *testing.T) {
 	}{
 		{nil, "nil"},
 
+		{true, "true"},
+		{false, "false"},
+
 		{1, "1"},
 		{-1, "-1"},
 
 		{"a", `a`},
 		{ns("a"), `a`},
+		{"1", `"1"`},
+		{"nil", `"nil"`},
+		{"", `""`},
+		{"#a", `"#a"`},
+		{"^a", `"^a"`},
+		{" a", `" a"`},
 
 		{[]int{}, ""},
 		{[]int{1, 2, 3}, "1\n2\n3"},
@@ -56,6 +73,24 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestUnmarshalNonPointer(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		err   string
+	}{
+		{struct{ A int }{}, "teff: Unmarshal(non-pointer struct { A int })"},
+		{(*int)(nil), "teff: Unmarshal(nil *int)"},
+		{nil, "teff: Unmarshal(nil)"},
+	} {
+		if err := Unmarshal([]byte("A:\n\t1"), testcase.value); err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+		if _, err := UnmarshalPartial([]byte("1"), testcase.value); err == nil || err.Error() != testcase.err {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
 func TestAlloc(t *testing.T) {
 	{
 		var p *int
@@ -77,6 +112,343 @@ func TestAlloc(t *testing.T) {
 	}
 }
 
+func TestReuse(t *testing.T) {
+	type record struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]int
+		Next  *int
+	}
+	var r record
+	if err := Unmarshal([]byte("Name:\n\ta\nTags:\n\tx\n\ty\nAttrs:\n\tk:\n\t\t1\nNext:\n\t1"), &r); err != nil {
+		t.Fatal(err)
+	}
+	next := r.Next
+	if err := Unmarshal([]byte("Tags:\n\tz\nAttrs:\n\tl:\n\t\t2\nNext:\n\t2"), &r); err != nil {
+		t.Fatal(err)
+	}
+	expected := record{Tags: []string{"z"}, Attrs: map[string]int{"l": 2}, Next: next}
+	if !reflect.DeepEqual(r, expected) {
+		t.Fatalf("expect %#v but got %#v", expected, r)
+	}
+	if r.Next != next || *next != 2 {
+		t.Fatal("expect the pointer to be reused")
+	}
+	if err := Unmarshal([]byte(""), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, record{}) {
+		t.Fatalf("expect zero value but got %#v", r)
+	}
+}
+
+func TestZeroBeforeDecode(t *testing.T) {
+	type record struct {
+		Name string
+		Tags []string
+		Next *int
+	}
+	for _, testcase := range []struct {
+		zero     bool
+		expected record
+	}{
+		{false, record{Name: "a", Tags: []string{"z"}, Next: pi(2)}},
+		{true, record{Tags: []string{"z"}, Next: pi(2)}},
+	} {
+		next := pi(1)
+		tags := []string{"x", "y"}
+		r := record{Name: "a", Tags: tags, Next: next}
+		dec := NewDecoder(strings.NewReader("Tags:\n\tz\nNext:\n\t2"))
+		dec.SetMerge(true)
+		dec.SetZeroBeforeDecode(testcase.zero)
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r, testcase.expected) {
+			t.Fatalf("expect %#v but got %#v", testcase.expected, r)
+		}
+		if reused := r.Next == next; reused == testcase.zero {
+			t.Fatalf("expect the pointer to be reused: %v, but got %v", !testcase.zero, reused)
+		}
+		if testcase.zero && (*next != 1 || tags[0] != "x") {
+			t.Fatalf("expect the old values untouched but got %v %v", *next, tags)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	type server struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name   string
+		Server *server
+		Attrs  map[string]int
+	}
+	srv := &server{Host: "a", Port: 80}
+	c := &config{Name: "x", Server: srv, Attrs: map[string]int{"k": 1}}
+	dec := NewDecoder(bytes.NewReader([]byte("Server:\n\tPort:\n\t\t8080\nAttrs:\n\tl:\n\t\t2")))
+	dec.SetMerge(true)
+	if err := dec.Decode(c); err != nil {
+		t.Fatal(err)
+	}
+	expected := &config{Name: "x", Server: &server{Host: "a", Port: 8080}, Attrs: map[string]int{"k": 1, "l": 2}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expect %#v but got %#v", expected, c)
+	}
+	if c.Server != srv {
+		t.Fatal("expect the pointee to be filled in place")
+	}
+}
+
+func TestStrict(t *testing.T) {
+	for i, testcase := range []struct {
+		text   string
+		value  interface{}
+		strict bool
+	}{
+		{`true`, true, true},
+		{`1`, true, false},
+		{`"true"`, true, false},
+		{`1`, 1, true},
+		{`"1"`, 1, false},
+		{`a`, "a", true},
+		{`"1"`, "1", true},
+		{`1`, "1", false},
+		{`true`, "true", false},
+		{`hello world`, "hello world", true},
+		{`"a\tb"`, "a\tb", true},
+		{"`a\\tb`", `a\tb`, true},
+		{`'a'`, "'a'", true},
+		{`a"b"`, `a"b"`, true},
+		{`"abc`, `"abc`, false},
+		{`123abc`, "123abc", true},
+	} {
+		for _, strict := range []bool{false, true} {
+			v := reflect.New(reflect.TypeOf(testcase.value))
+			dec := NewDecoder(bytes.NewReader([]byte(testcase.text)))
+			dec.SetStrict(strict)
+			err := dec.Decode(v.Interface())
+			if strict && !testcase.strict {
+				if err == nil {
+					t.Fatalf("testcase %d: expect error in strict mode but got nil", i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("testcase %d: %v", i, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), testcase.value) {
+				t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v.Elem().Interface())
+			}
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	const (
+		always = iota
+		lenientOnly
+		never
+	)
+	for i, testcase := range []struct {
+		text     string
+		value    interface{}
+		accepted int
+	}{
+		{`"5"`, 5, always},
+		{`1`, true, always},
+		{`5`, "5", always},
+		{`5.0`, 5, lenientOnly},
+		{`"-5.0"`, -5, lenientOnly},
+		{`1e3`, 1000, lenientOnly},
+		{`yes`, true, lenientOnly},
+		{`OFF`, false, lenientOnly},
+		{`"n"`, false, lenientOnly},
+		{`5.5`, 0, never},
+		{`1e20`, 0, never},
+		{`Inf`, 0, never},
+		{`2`, false, never},
+		{`maybe`, false, never},
+	} {
+		for _, lenient := range []bool{false, true} {
+			v := reflect.New(reflect.TypeOf(testcase.value))
+			dec := NewDecoder(bytes.NewReader([]byte(testcase.text)))
+			dec.SetLenient(lenient)
+			err := dec.Decode(v.Interface())
+			if testcase.accepted == never || testcase.accepted == lenientOnly && !lenient {
+				if err == nil {
+					t.Fatalf("testcase %d: expect error but got nil", i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("testcase %d: %v", i, err)
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), testcase.value) {
+				t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v.Elem().Interface())
+			}
+
+			dec = NewDecoder(bytes.NewReader([]byte(testcase.text)))
+			dec.SetLenient(lenient)
+			dec.SetStrict(true)
+			if err := dec.Decode(v.Interface()); err == nil {
+				t.Fatalf("testcase %d: expect error in strict mode but got nil", i)
+			}
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	data := []byte("a\nb\x00\nc")
+	var v []string
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.SetRecover(true)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []string{"a", "c"}) {
+		t.Fatalf("expect [a c] but got %v", v)
+	}
+	if len(dec.Errors()) != 1 {
+		t.Fatalf("expect 1 error but got %v", dec.Errors())
+	}
+	if err := Unmarshal(data, &v); err == nil {
+		t.Fatal("expect error without recovery")
+	}
+}
+
+func TestValue(t *testing.T) {
+	s := struct {
+		I int
+		S []string
+	}{1, []string{"a", "b"}}
+	buf, err := MarshalValue(reflect.ValueOf(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "I:\n\t1\nS:\n\ta\n\tb"
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, string(buf))
+	}
+	v := reflect.New(reflect.TypeOf(s)).Elem()
+	if err := UnmarshalValue(buf, v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Interface(), s) {
+		t.Fatalf("expect %v but got %v", s, v.Interface())
+	}
+	if err := UnmarshalValue(buf, reflect.ValueOf(s)); err == nil {
+		t.Fatal("expect error for unsettable value")
+	}
+	if err := UnmarshalValue(buf, reflect.Value{}); err == nil {
+		t.Fatal("expect error for invalid value")
+	}
+}
+
+func TestUnmarshalPartial(t *testing.T) {
+	{
+		data := []byte("1\n\n2")
+		var i int
+		rest, err := UnmarshalPartial(data, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 1 || string(rest) != "2" {
+			t.Fatalf("expect 1 and rest 2 but got %d and %q", i, rest)
+		}
+		rest, err = UnmarshalPartial(rest, &i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 2 || len(rest) != 0 {
+			t.Fatalf("expect 2 and empty rest but got %d and %q", i, rest)
+		}
+		if _, err := UnmarshalPartial(rest, &i); err != io.EOF {
+			t.Fatalf("expect EOF but got %v", err)
+		}
+	}
+	{
+		data := []byte("_\n\tI:\n\t\t1\n_\n\tI:\n\t\t2")
+		var values []int
+		for len(data) > 0 {
+			var s struct{ I int }
+			var err error
+			data, err = UnmarshalPartial(data, &s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, s.I)
+		}
+		if !reflect.DeepEqual(values, []int{1, 2}) {
+			t.Fatalf("expect [1 2] but got %v", values)
+		}
+	}
+}
+
+func TestMarshalToTree(t *testing.T) {
+	type tls struct {
+		Cert string
+	}
+	type server struct {
+		Host  string
+		Ports []int
+		TLS   *tls
+	}
+	value := server{"a", []int{80, 443}, &tls{"c.pem"}}
+	tree, err := MarshalToTree(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := core.List{
+		{Value: "Host:", List: core.List{{Value: "a"}}},
+		{Value: "Ports:", List: core.List{{Value: "80"}, {Value: "443"}}},
+		{Value: "TLS:", List: core.List{
+			{Value: "Cert:", List: core.List{{Value: "c.pem"}}},
+		}},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Fatalf("expect \n%#v\nbut got \n%#v", expected, tree)
+	}
+	tree[0].List[0].Value = "b"
+	var w bytes.Buffer
+	if err := tree.Marshal(&w, "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded server
+	if err := Unmarshal(w.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if value.Host = "b"; !reflect.DeepEqual(decoded, value) {
+		t.Fatalf("expect %#v but got %#v", value, decoded)
+	}
+	if tree, err := MarshalToTree(nil); err != nil || !reflect.DeepEqual(tree, core.List{{Value: "nil"}}) {
+		t.Fatalf("expect a nil tree but got %#v, %v", tree, err)
+	}
+}
+
+func TestUnmarshalPath(t *testing.T) {
+	data := []byte("server:\n\tport: 443\n\ttls:\n\t\tcert:\n\t\t\ta.pem\nname:\n\tx")
+	var cert string
+	if err := UnmarshalPath(data, "server.tls.cert", &cert); err != nil {
+		t.Fatal(err)
+	}
+	if cert != "a.pem" {
+		t.Fatalf("expect a.pem but got %s", cert)
+	}
+	var port int
+	if err := UnmarshalPath(data, "server.port", &port); err != nil {
+		t.Fatal(err)
+	}
+	if port != 443 {
+		t.Fatalf("expect 443 but got %d", port)
+	}
+	if err := UnmarshalPath(data, "server.tls.key", &cert); err == nil || !strings.Contains(err.Error(), "key key not found") {
+		t.Fatalf("expect key not found error but got %v", err)
+	}
+}
+
 func newValueOf(v interface{}) interface{} {
 	if v == nil {
 		return nil
@@ -89,3 +461,365 @@ var p = fmt.Println
 func ns(s string) *string {
 	return &s
 }
+
+func TestGroupSpacing(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetGroupSpacing(true)
+	if err := enc.Encode([]string{"a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "a\n\nb\n\nc"
+	if w.String() != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, w.String())
+	}
+	var v []string
+	if err := Unmarshal(w.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Fatalf("expect [a b c] but got %v", v)
+	}
+}
+
+func TestEmptyString(t *testing.T) {
+	type record struct {
+		Name string
+		Note *string
+	}
+	r := record{Name: "", Note: ns("")}
+	text := "Name:\n\t\"\"\nNote:\n\t\"\""
+	buf, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, string(buf))
+	}
+	var decoded record
+	if err := Unmarshal(buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, r) {
+		t.Fatalf("expect %#v but got %#v", r, decoded)
+	}
+	if err := Unmarshal([]byte("Name:"), &decoded); err == nil {
+		t.Fatal("expect error for a missing value")
+	}
+	if err := Unmarshal([]byte(""), &decoded); err != nil || decoded.Note != nil {
+		t.Fatalf("expect an absent field to be nil but got %v, %v", decoded.Note, err)
+	}
+}
+
+func TestElementAnnotator(t *testing.T) {
+	type entry struct {
+		Name string
+	}
+	v := struct {
+		Entries []entry
+		Skipped []int
+	}{[]entry{{"a"}, {"b"}, {"c"}}, []int{1, 2}}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetElementAnnotator(func(i int, elem interface{}) string {
+		if e, ok := elem.(entry); ok {
+			return fmt.Sprintf(" entry %d of 3\n %s", i+1, e.Name)
+		}
+		return ""
+	})
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	text := "Entries:\n\t# entry 1 of 3\n\t# a\n\t_\n\t\tName:\n\t\t\ta\n\t# entry 2 of 3\n\t# b\n\t_\n\t\tName:\n\t\t\tb\n\t# entry 3 of 3\n\t# c\n\t_\n\t\tName:\n\t\t\tc\nSkipped:\n\t1\n\t2"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	decoded := v
+	decoded.Entries, decoded.Skipped = nil, nil
+	if err := Unmarshal(w.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Fatalf("expect %#v but got %#v", v, decoded)
+	}
+}
+
+func TestTrailingNewline(t *testing.T) {
+	for i, testcase := range []struct {
+		newline bool
+		value   interface{}
+		text    string
+	}{
+		{false, []string{"a", "b"}, "a\nb"},
+		{true, []string{"a", "b"}, "a\nb\n"},
+		{true, []string{}, ""},
+		{true, struct{ A []int }{[]int{1}}, "A:\n\t1\n"},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SetTrailingNewline(testcase.newline)
+		if err := enc.Encode(testcase.value); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect %q but got %q", i, testcase.text, w.String())
+		}
+	}
+}
+
+func TestWrapScalars(t *testing.T) {
+	var s []string
+	if err := Unmarshal([]byte("a"), &s); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, []string{"a"}) {
+		t.Fatalf("expect [a] but got %v", s)
+	}
+
+	text := "_\n\ta\n\tb\nc"
+	var ss [][]string
+	if err := Unmarshal([]byte(text), &ss); err == nil {
+		t.Fatal("expect error but got nil")
+	}
+	dec := NewDecoder(bytes.NewReader([]byte(text)))
+	dec.SetWrapScalars(true)
+	if err := dec.Decode(&ss); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"a", "b"}, {"c"}}
+	if !reflect.DeepEqual(ss, expected) {
+		t.Fatalf("expect %v but got %v", expected, ss)
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	var w1, w2 bytes.Buffer
+	enc := NewEncoder(&w1)
+	enc.SetIndent("", "  ")
+	a := ns("a")
+	if err := enc.Encode([]*string{a, a}); err != nil {
+		t.Fatal(err)
+	}
+	enc.Reset(&w2)
+	if err := enc.Encode(struct{ A []int }{[]int{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if text := "# ^1\na\n^1"; w1.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w1.String())
+	}
+	if text := "A:\n  1"; w2.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w2.String())
+	}
+}
+
+// latin1Reader transcodes ISO 8859-1 to UTF-8 as charmap.ISO8859_1 does.
+type latin1Reader struct {
+	r   io.ByteReader
+	buf []byte
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			if len(r.buf) == 0 {
+				return 0, err
+			}
+			break
+		}
+		r.buf = append(r.buf, string(rune(b))...)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestTransform(t *testing.T) {
+	text := "Name:\n\tJos\xe9\nCity:\n\tM\xfcnchen"
+	var v struct{ Name, City string }
+	if err := Unmarshal([]byte(text), &v); err == nil {
+		t.Fatal("expect error for invalid UTF-8")
+	}
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetTransform(func(r io.Reader) io.Reader {
+		return &latin1Reader{r: bufio.NewReader(r)}
+	})
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "José" || v.City != "München" {
+		t.Fatalf("unexpected value %#v", v)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	type server struct {
+		Name  string
+		Ports []int
+		Note  string
+	}
+	value := []server{{"a", []int{80, 443}, "café\nnaïve"}, {"b", nil, "日本"}}
+	buf, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(buf)
+	zw.Close()
+	for i, r := range []io.Reader{
+		iotest.OneByteReader(bytes.NewReader(compressed.Bytes())),
+		iotest.HalfReader(bytes.NewReader(compressed.Bytes())),
+		iotest.OneByteReader(bytes.NewReader(buf)),
+	} {
+		dec := NewDecoder(r)
+		dec.SetDecompress(true)
+		var decoded []server
+		if err := dec.Decode(&decoded); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, value, decoded)
+		}
+	}
+	var names []string
+	err = (&Codec{Decompress: true}).NewDecoder(iotest.OneByteReader(bytes.NewReader(compressed.Bytes()))).DecodeEach(func(i int, dec *Decoder) error {
+		var s server
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		names = append(names, s.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[a b]" {
+		t.Fatalf("expect [a b] but got %v", names)
+	}
+	if err := Unmarshal(compressed.Bytes(), &value); err == nil {
+		t.Fatal("expect error for a compressed input unless decompressing")
+	}
+	dec := NewDecoder(bytes.NewReader(compressed.Bytes()[:20]))
+	dec.SetDecompress(true)
+	if err := dec.Decode(&value); err == nil {
+		t.Fatal("expect error for a truncated compressed input")
+	}
+}
+
+func TestNumericBool(t *testing.T) {
+	type flags struct {
+		A, B bool
+		C    []bool
+	}
+	value := flags{true, false, []bool{false, true}}
+	expected := "A:\n\t1\nB:\n\t0\nC:\n\t0\n\t1"
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetNumericBool(true)
+	if err := enc.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, w.String())
+	}
+	var v flags
+	if err := Unmarshal(w.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, value) {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+}
+
+func TestBoolTokens(t *testing.T) {
+	type flags struct {
+		A, B bool
+		C    []bool
+		D    interface{}
+	}
+	value := flags{true, false, []bool{false, true}, map[string]interface{}{"x": true, "y": "true", "z": "ja"}}
+	expected := "A:\n\tja\nB:\n\tnein\nC:\n\tnein\n\tja\nD:\n\tx:\n\t\tja\n\ty:\n\t\t\"true\"\n\tz:\n\t\t\"ja\""
+	c := &Codec{TrueTokens: []string{"ja", "j"}, FalseTokens: []string{"nein", "n"}}
+	buf, err := c.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+	}
+	var v flags
+	if err := c.Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, value) {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+	for i, testcase := range []struct {
+		text  string
+		value bool
+		ok    bool
+	}{
+		{"j", true, true},
+		{`"n"`, false, true},
+		{"true", false, false},
+		{"1", false, false},
+		{"Ja", false, false},
+	} {
+		var b bool
+		err := c.Unmarshal([]byte(testcase.text), &b)
+		if !testcase.ok {
+			if err == nil {
+				t.Fatalf("testcase %d: expect error but got nil", i)
+			}
+			continue
+		}
+		if err != nil || b != testcase.value {
+			t.Fatalf("testcase %d: expect %v but got %v, %v", i, testcase.value, b, err)
+		}
+	}
+	for i, c := range []*Codec{
+		{TrueTokens: []string{"ja"}},
+		{TrueTokens: []string{"ja", "x"}, FalseTokens: []string{"nein", "x"}},
+		{TrueTokens: []string{""}, FalseTokens: []string{"nein"}},
+		{TrueTokens: []string{"ja"}, FalseTokens: []string{"#x"}},
+		{TrueTokens: []string{"ja"}, FalseTokens: []string{"nil"}},
+		{TrueTokens: []string{"1"}, FalseTokens: []string{"0"}},
+		{TrueTokens: []string{"ja"}, FalseTokens: []string{"-"}, NilToken: "-"},
+	} {
+		if _, err := c.Marshal(true); err == nil {
+			t.Fatalf("testcase %d: expect error to marshal but got nil", i)
+		}
+		var b bool
+		if err := c.Unmarshal([]byte("ja"), &b); err == nil {
+			t.Fatalf("testcase %d: expect error to unmarshal but got nil", i)
+		}
+	}
+}
+
+func TestOddIndent(t *testing.T) {
+	type node struct {
+		Name  string
+		Note  string
+		Nodes []node
+	}
+	value := node{Name: "a", Note: "x\n  y\nz", Nodes: []node{{Name: "b", Nodes: []node{{Name: "c", Note: "w"}}}, {Name: "d"}}}
+	for _, indent := range []string{"   ", "     "} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetIndent("", indent)
+		if err := enc.Encode(value); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "\n"+indent+indent+"Name:\n"+indent+indent+indent+"b") {
+			t.Fatalf("%d spaces: unexpected indent of \n%s", len(indent), buf.String())
+		}
+		var decoded node
+		if err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("%d spaces: %v", len(indent), err)
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Fatalf("%d spaces: expect %#v but got %#v", len(indent), value, decoded)
+		}
+	}
+}
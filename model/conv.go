@@ -13,10 +13,16 @@ func New(v interface{}) (*Node, error) {
 }
 
 func (n *Node) Fill(v interface{}) error {
+	return n.FillLimited(v, Limits{})
+}
+
+// FillLimited is like Fill but fails when the labels or references in n exceed
+// limits.
+func (n *Node) FillLimited(v interface{}, limits Limits) error {
 	if v == nil {
 		return nil
 	}
-	return newFiller().nodeTo(n, reflect.ValueOf(v))
+	return newFiller(limits).nodeTo(n, reflect.ValueOf(v))
 }
 
 func (m *maker) toNode(v reflect.Value) (*Node, error) {
@@ -39,6 +45,9 @@ func (m *maker) toNode(v reflect.Value) (*Node, error) {
 }
 
 func (f *filler) nodeTo(node *Node, v reflect.Value) error {
+	if err := f.register(node, v); err != nil {
+		return err
+	}
 	switch v.Type().Kind() {
 	case reflect.Int, reflect.String:
 		if value, ok := node.C.(Value); ok {
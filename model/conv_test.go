@@ -70,17 +70,30 @@ func TestModel(t *testing.T) {
 			),
 		},
 
-		// {
-		// 	func() []*int {
-		// 		i := pi(3)
-		// 		return []*int{i, i}
-		// 	}(),
-		// 	array(
-		// 		value(3).Ref("1"),
-		// 		value(RefID("1")),
-		// 	),
-		// },
-		//
+		{
+			func() []*int {
+				i := pi(3)
+				return []*int{i, i}
+			}(),
+			array(
+				value(3).Ref("1"),
+				value(RefID("1")),
+			),
+		},
+
+		{
+			func() []*int {
+				i, j := pi(3), pi(4)
+				return []*int{i, j, i, j}
+			}(),
+			array(
+				value(3).Ref("1"),
+				value(4).Ref("2"),
+				value(RefID("1")),
+				value(RefID("2")),
+			),
+		},
+
 		// {
 		// 	struct{}{},
 		// 	List{},
@@ -239,6 +252,62 @@ func TestModel(t *testing.T) {
 	}
 }
 
+func TestSharedScalar(t *testing.T) {
+	i := pi(1)
+	node, err := New([]*int{i, pi(1), i})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v []*int
+	if err := node.Fill(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 3 || v[0] != v[2] || v[0] == v[1] || *v[0] != 1 || *v[1] != 1 {
+		t.Fatalf("expect the first and the last pointers to be shared but got %v", v)
+	}
+}
+
+func TestFillLimited(t *testing.T) {
+	chain := array(
+		value(1).Ref("1"),
+		value(RefID("1")).Ref("2"),
+		value(RefID("2")).Ref("3"),
+		value(RefID("3")),
+	)
+	{
+		var v []*int
+		if err := chain.Fill(&v); err != nil {
+			t.Fatal(err)
+		}
+		for i := range v {
+			if v[i] != v[0] {
+				t.Fatalf("expect shared pointer but got %v", v)
+			}
+		}
+	}
+	for i, limits := range []Limits{
+		{MaxLabels: 2},
+		{MaxRefDepth: 2},
+	} {
+		var v []*int
+		if err := chain.FillLimited(&v, limits); err == nil {
+			t.Fatalf("testcase %d: expect error when exceeding %v", i, limits)
+		}
+	}
+	{
+		var v []*int
+		if err := chain.FillLimited(&v, Limits{MaxLabels: 3, MaxRefDepth: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{
+		var v []*int
+		if err := array(value(RefID("1"))).Fill(&v); err == nil {
+			t.Fatal("expect error for undefined label")
+		}
+	}
+}
+
 func newValueOf(v interface{}) interface{} {
 	if v == nil {
 		return nil
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"reflect"
 	"strconv"
 )
@@ -18,11 +19,24 @@ type nodeRegistry struct {
 // filler fills from a list
 // TODO: fill lazily
 type filler struct {
-	m map[RefID]reflect.Value
+	m      map[RefID]reflect.Value
+	refs   map[RefID]RefID // labeled nodes that are references themselves
+	limits Limits
 }
 
-func newFiller() *filler {
-	return &filler{make(map[RefID]reflect.Value)}
+// Limits restricts the labels and references resolved by Fill to guard against
+// crafted input. A zero field means no limit.
+type Limits struct {
+	MaxLabels   int // maximum number of distinct RefIDs
+	MaxRefDepth int // maximum length of a chain of references
+}
+
+func newFiller(limits Limits) *filler {
+	return &filler{
+		m:      make(map[RefID]reflect.Value),
+		refs:   make(map[RefID]RefID),
+		limits: limits,
+	}
 }
 
 func newMaker() *maker {
@@ -43,8 +57,42 @@ func (m *maker) find(addr uintptr) (*Node, bool) {
 	return nil, false
 }
 
-func (f *filler) value(refID RefID) reflect.Value {
-	return f.m[refID]
+func (f *filler) register(n *Node, v reflect.Value) error {
+	if n.RefID == "" {
+		return nil
+	}
+	if _, ok := f.m[n.RefID]; !ok && f.limits.MaxLabels > 0 && len(f.m) >= f.limits.MaxLabels {
+		return fmt.Errorf("filler.register: number of labels exceeds %d", f.limits.MaxLabels)
+	}
+	f.m[n.RefID] = v
+	if value, ok := n.C.(Value); ok {
+		if ref, ok := value.V.(RefID); ok {
+			f.refs[n.RefID] = ref
+		}
+	}
+	return nil
+}
+
+func (f *filler) value(refID RefID) (reflect.Value, error) {
+	depth := 1
+	for r := refID; ; depth++ {
+		if f.limits.MaxRefDepth > 0 && depth > f.limits.MaxRefDepth {
+			return reflect.Value{}, fmt.Errorf("filler.value: reference depth of %s exceeds %d", refID, f.limits.MaxRefDepth)
+		}
+		if depth > len(f.refs)+1 {
+			return reflect.Value{}, fmt.Errorf("filler.value: reference cycle of %s", refID)
+		}
+		next, ok := f.refs[r]
+		if !ok {
+			break
+		}
+		r = next
+	}
+	v, ok := f.m[refID]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("filler.value: undefined label %s", refID)
+	}
+	return v, nil
 }
 
 func (f *filler) nodeToPtr(n *Node, v reflect.Value) error {
@@ -58,18 +106,30 @@ func (m *maker) ptrToNode(v reflect.Value) (*Node, error) {
 	if v.IsNil() {
 		return nil, nil // avoid infinite loop
 	}
+	var addr uintptr
 	for v.Type().Kind() == reflect.Ptr {
 		if refNode, ok := m.find(v.Pointer()); ok {
 			return &Node{C: Value{refNode.RefID}}, nil
 		}
+		addr = v.Pointer()
 		v = reflect.Indirect(v)
 	}
-	return m.toNode(v)
+	node, err := m.toNode(v)
+	if err != nil {
+		return nil, err
+	}
+	// register the pointed value so that a later pointer to it is a reference,
+	// including a pointer to a scalar.
+	m.m[addr] = nodeRegistry{node: node, isSource: true}
+	return node, nil
 }
 
 func (f *filler) valueToPtr(v Value, o reflect.Value) error {
 	if refID, ok := v.V.(RefID); ok {
-		ref := f.value(refID)
+		ref, err := f.value(refID)
+		if err != nil {
+			return err
+		}
 		if ref.Type() != o.Type() {
 			ref = ref.Addr()
 			for o.Type() != ref.Type() {
@@ -0,0 +1,104 @@
+package teff
+
+import "io"
+
+// Options bundles the options of Encode and Decode, the zero value or a nil
+// Options means the default behavior of Marshal and Unmarshal.
+type Options struct {
+	// Prefix is written at the start of each line.
+	Prefix string
+	// Indent is written for each indent level, empty means a tab.
+	Indent string
+	// Strict makes the decoder require the literal of a scalar to match the
+	// type of its destination exactly, see Decoder.SetStrict.
+	Strict bool
+	// MaxStringLength is the maximum length in bytes of a decoded string, zero
+	// means no limit.
+	MaxStringLength int
+	// MaxIndent is the maximum number of characters of an indent, zero means
+	// no limit.
+	MaxIndent int
+	// SortFields makes the encoder write struct fields in the order of their
+	// encoded names rather than the declaration order.
+	SortFields bool
+	// Canonical makes the encoder produce canonical output, see
+	// Encoder.SetCanonical.
+	Canonical bool
+	// EscapeNonASCII makes the encoder quote strings containing non-ASCII
+	// characters with only ASCII characters.
+	EscapeNonASCII bool
+	// Header is a comment written before the data, see Encoder.SetHeader.
+	Header string
+}
+
+// Encode writes the encoding of v to w with the options.
+func Encode(w io.Writer, v interface{}, opts *Options) error {
+	enc := NewEncoder(w)
+	if opts != nil {
+		indent := opts.Indent
+		if indent == "" {
+			indent = "\t"
+		}
+		enc.SetIndent(opts.Prefix, indent)
+		enc.SetSortFields(opts.SortFields)
+		enc.SetCanonical(opts.Canonical)
+		enc.SetEscapeNonASCII(opts.EscapeNonASCII)
+		enc.SetHeader(opts.Header)
+	}
+	return enc.Encode(v)
+}
+
+// Decode reads the encoding of v from r with the options.
+func Decode(r io.Reader, v interface{}, opts *Options) error {
+	dec := NewDecoder(r)
+	if opts != nil {
+		dec.SetStrict(opts.Strict)
+		dec.SetMaxStringLength(opts.MaxStringLength)
+		dec.SetMaxIndent(opts.MaxIndent)
+	}
+	return dec.Decode(v)
+}
+
+// Option is a functional option of MarshalOpts and UnmarshalOpts, which sets a
+// field of the Codec used for the call.
+type Option func(*Codec)
+
+// WithIndent sets the prefix of each line and the string of each indent level.
+func WithIndent(prefix, indent string) Option {
+	return func(c *Codec) { c.Prefix, c.Indent = prefix, indent }
+}
+
+// WithStrict makes the decoder strict, see Decoder.SetStrict.
+func WithStrict() Option {
+	return func(c *Codec) { c.Strict = true }
+}
+
+// WithSortedKeys makes the encoder write struct fields in the order of their
+// encoded names, map entries are always sorted by keys.
+func WithSortedKeys() Option {
+	return func(c *Codec) { c.SortFields = true }
+}
+
+// WithInline makes the encoder write scalar values on the same lines as their
+// keys, see Encoder.SetInline.
+func WithInline() Option {
+	return func(c *Codec) { c.Inline = true }
+}
+
+// MarshalOpts is like Marshal but with the options.
+func MarshalOpts(v interface{}, opts ...Option) ([]byte, error) {
+	return newCodec(opts).Marshal(v)
+}
+
+// UnmarshalOpts is like Unmarshal but with the options.
+func UnmarshalOpts(data []byte, v interface{}, opts ...Option) error {
+	return newCodec(opts).Unmarshal(data, v)
+}
+
+func newCodec(opts []Option) *Codec {
+	c := &Codec{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
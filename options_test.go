@@ -0,0 +1,128 @@
+package teff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	type city struct {
+		Zone  string
+		Name  string
+		Alias []string
+	}
+	c := city{Zone: "CET", Name: "Zürich", Alias: []string{"Zurich"}}
+	var w bytes.Buffer
+	opts := &Options{Prefix: "> ", Indent: "  ", SortFields: true, EscapeNonASCII: true, Strict: true}
+	if err := Encode(&w, c, opts); err != nil {
+		t.Fatal(err)
+	}
+	text := "> Alias:\n>   Zurich\n> Name:\n>   \"Z\\u00fcrich\"\n> Zone:\n>   CET"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	var decoded city
+	if err := Decode(bytes.NewReader(bytes.Replace(w.Bytes(), []byte("> "), nil, -1)), &decoded, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, c) {
+		t.Fatalf("expect %#v but got %#v", c, decoded)
+	}
+	var n int
+	if err := Decode(bytes.NewReader([]byte(`"1"`)), &n, opts); err == nil {
+		t.Fatal("expect error for a quoted int in strict mode")
+	}
+	w.Reset()
+	if err := Encode(&w, c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if text := "Zone:\n\tCET\nName:\n\tZürich\nAlias:\n\tZurich"; w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+}
+
+func TestHeader(t *testing.T) {
+	v := struct{ A []int }{[]int{1}}
+	var w bytes.Buffer
+	if err := Encode(&w, v, &Options{Header: "generated by x\ndo not edit"}); err != nil {
+		t.Fatal(err)
+	}
+	text := "# generated by x\n# do not edit\nA:\n\t1"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	var decoded struct{ A []int }
+	if err := Unmarshal(w.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Fatalf("expect %#v but got %#v", v, decoded)
+	}
+}
+
+func TestMaxStringLength(t *testing.T) {
+	opts := &Options{MaxStringLength: 3}
+	var s string
+	if err := Decode(bytes.NewReader([]byte("abc")), &s, opts); err != nil || s != "abc" {
+		t.Fatalf("expect abc but got %q, %v", s, err)
+	}
+	for _, text := range []string{"abcd", `"abcd"`, "|\n\tab\n\tc"} {
+		err := Decode(bytes.NewReader([]byte(text)), &s, opts)
+		if err == nil || !strings.Contains(err.Error(), "exceeds the maximum length 3") {
+			t.Fatalf("%q: expect length error but got %v", text, err)
+		}
+	}
+}
+
+func TestMaxIndent(t *testing.T) {
+	var v map[string][]int
+	text := "a:\n" + strings.Repeat(" ", 1<<20) + "1"
+	if err := Decode(strings.NewReader(text), &v, &Options{MaxIndent: 8}); err == nil {
+		t.Fatal("expect error for an indent exceeding the maximum")
+	}
+	if err := Decode(strings.NewReader("a:\n    1"), &v, &Options{MaxIndent: 8}); err != nil || v["a"][0] != 1 {
+		t.Fatalf("expect a:[1] but got %v, %v", v, err)
+	}
+	for i, setup := range []func(*Decoder){
+		func(dec *Decoder) {},
+		func(dec *Decoder) { dec.SetRecover(true) },
+		func(dec *Decoder) { dec.SetLocations(true) },
+	} {
+		dec := (&Codec{MaxIndent: 8}).NewDecoder(strings.NewReader(text))
+		setup(dec)
+		if err := dec.Decode(&v); err == nil && len(dec.Errors()) == 0 {
+			t.Fatalf("testcase %d: expect the long indent rejected", i)
+		}
+	}
+	err := (&Codec{MaxIndent: 8}).NewDecoder(strings.NewReader(text)).DecodeEach(func(int, *Decoder) error { return nil })
+	if err == nil {
+		t.Fatal("expect error for an indent exceeding the maximum in DecodeEach")
+	}
+}
+
+func TestFunctionalOptions(t *testing.T) {
+	type server struct {
+		Port int
+		Host string
+	}
+	s := server{80, "a"}
+	buf, err := MarshalOpts(s, WithSortedKeys(), WithInline(), WithIndent("", "  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := "Host: a\nPort: 80"; string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	var decoded server
+	if err := UnmarshalOpts(buf, &decoded, WithStrict()); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != s {
+		t.Fatalf("expect %#v but got %#v", s, decoded)
+	}
+	if err := UnmarshalOpts([]byte(`Port: "80"`), &decoded, WithStrict()); err == nil {
+		t.Fatal("expect error for a quoted int in strict mode")
+	}
+}
@@ -0,0 +1,54 @@
+package teff
+
+import (
+	"fmt"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// SetKeyValuePairs sets whether a map or a struct may also be decoded from a
+// single line of key=value pairs separated by spaces or commas, e.g. "a=1 b=2".
+// A value containing spaces, commas or "=" must be quoted.
+func (dec *Decoder) SetKeyValuePairs(pairs bool) {
+	dec.pairs = pairs
+}
+
+// pairList converts a single line of key=value pairs to a list of key-value
+// pairs if enabled, otherwise the list is returned as is.
+func (dec *Decoder) pairList(list core.List) (core.List, error) {
+	if !dec.pairs || len(list) != 1 || list[0].IsReference || len(list[0].List) > 0 ||
+		!strings.Contains(list[0].Value, "=") {
+		return list, nil
+	}
+	if _, _, ok := splitKeyValue(list[0]); ok {
+		return list, nil
+	}
+	return splitPairs(list[0].Value)
+}
+
+const pairSeparators = " \t,"
+
+func splitPairs(s string) (core.List, error) {
+	var list core.List
+	for s = strings.TrimLeft(s, pairSeparators); s != ""; s = strings.TrimLeft(s, pairSeparators) {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 || strings.ContainsAny(s[:i], pairSeparators) {
+			return nil, fmt.Errorf("invalid key=value pair: %s", s)
+		}
+		key, rest := s[:i], s[i+1:]
+		end := strings.IndexAny(rest, pairSeparators)
+		if rest != "" && (rest[0] == '"' || rest[0] == '`') {
+			end = quoteEnd(rest)
+			if end < 0 || end < len(rest) && !strings.ContainsRune(pairSeparators, rune(rest[end])) {
+				return nil, fmt.Errorf("invalid quoted value of %s: %s", key, rest)
+			}
+		}
+		if end < 0 {
+			end = len(rest)
+		}
+		list = append(list, core.Node{Value: key + ":", List: core.List{{Value: rest[:end]}}})
+		s = rest[end:]
+	}
+	return list, nil
+}
@@ -0,0 +1,39 @@
+package teff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyValuePairs(t *testing.T) {
+	c := &Codec{KeyValuePairs: true}
+	var m map[string]int
+	if err := c.Unmarshal([]byte("a=1 b=2"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expect %v but got %v", expected, m)
+	}
+
+	type option struct {
+		Name  string
+		Value string
+	}
+	var o struct{ Opt option }
+	if err := c.Unmarshal([]byte(`Opt: Name=x, Value="a=b, c"`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (option{"x", "a=b, c"}); o.Opt != expected {
+		t.Fatalf("expect %v but got %v", expected, o.Opt)
+	}
+
+	for _, text := range []string{"a=1 b", `a="1`, `a="1"2`} {
+		if err := c.Unmarshal([]byte(text), &m); err == nil {
+			t.Fatalf("%s: expect error but got nil", text)
+		}
+	}
+	if err := Unmarshal([]byte("a=1 b=2"), &m); err == nil || !strings.Contains(err.Error(), "colon") {
+		t.Fatalf("expect error without the option but got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+
+	"h12.io/teff/core"
+)
+
+// RegisterPositional registers a struct type t to be encoded as a list of its
+// field values in the order of the fields without keys, one line each, e.g. a
+// Point{X, Y} as:
+//
+//	3
+//	4
+//
+// It is decoded by the order of the fields, so the number of values must match
+// the number of fields.
+func RegisterPositional(t reflect.Type) {
+	globalRegistry.RegisterPositional(t)
+}
+
+// RegisterPositional is like the function RegisterPositional but registers into
+// r.
+func (r *Registry) RegisterPositional(t reflect.Type) {
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("teff: registering positional of non-struct type %v", t))
+	}
+	for _, f := range structFields(t) {
+		if f.method != "" || f.comments {
+			panic(fmt.Sprintf("teff: registering positional %v with method or comments field %s", t, f.name))
+		}
+		if f.secret {
+			panic(fmt.Sprintf("teff: registering positional %v with secret field %s", t, f.name))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.positionals[t] = true
+}
+
+func (enc *Encoder) marshalPositional(v reflect.Value) (core.List, error) {
+	fields := structFields(v.Type())
+	list := make(core.List, len(fields))
+	for i, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			fv = reflect.Zero(v.Type().FieldByIndex(f.index).Type)
+		}
+		var err error
+		if list[i], err = enc.marshalNode(fv); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (dec *Decoder) unmarshalPositional(list core.List, v reflect.Value) error {
+	fields := structFields(v.Type())
+	if len(list) != len(fields) {
+		return fmt.Errorf("expect %d values of %v but got %d", len(fields), v.Type(), len(list))
+	}
+	for i, f := range fields {
+		if err := dec.unmarshalNode(list[i], allocFieldByIndex(v, f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+type position struct {
+	X, Y int
+}
+
+type segment struct {
+	Name     string
+	From, To position
+	Tags     []string
+}
+
+func init() {
+	RegisterPositional(reflect.TypeOf(position{}))
+	RegisterPositional(reflect.TypeOf(segment{}))
+}
+
+func TestPositional(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{position{3, 4}, "3\n4"},
+		{[]position{{1, 2}, {3, 4}}, "_\n\t1\n\t2\n_\n\t3\n\t4"},
+		{struct{ P position }{position{3, 4}}, "P:\n\t3\n\t4"},
+		{segment{"a", position{1, 2}, position{3, 4}, []string{"x", "y"}}, "a\n_\n\t1\n\t2\n_\n\t3\n\t4\n_\n\tx\n\ty"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+	var p position
+	if err := Unmarshal([]byte("1\n2\n3"), &p); err == nil {
+		t.Fatal("expect error for a mismatched number of values")
+	}
+}
+
+func TestPositionalSecret(t *testing.T) {
+	type credential struct {
+		User     string
+		Password string `teff:"password,secret"`
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic for a secret field")
+		}
+	}()
+	RegisterPositional(reflect.TypeOf(credential{}))
+}
@@ -0,0 +1,176 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// Info describes a marshalled document.
+type Info struct {
+	// Labels are the labels assigned to the pointers shared within the value,
+	// in the order of their first occurrence.
+	Labels []Label
+}
+
+// Label is a label assigned to a shared pointer. The value it points to is
+// annotated with "# ^Name" at its first occurrence, and each later occurrence
+// is encoded as the reference "^Name". Labels are numbered from 1 in the order
+// of the output, so structurally equal values are encoded with equal labels.
+type Label struct {
+	Name string
+	Addr uintptr
+}
+
+// MarshalWithInfo is like Marshal but also returns the labels used in the
+// document.
+func MarshalWithInfo(v interface{}) ([]byte, Info, error) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	if err := enc.Encode(v); err != nil {
+		return nil, Info{}, err
+	}
+	if enc.refs == nil {
+		return w.Bytes(), Info{}, nil
+	}
+	return w.Bytes(), Info{Labels: enc.refs.labels}, nil
+}
+
+type refKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// refRegister finds the pointers shared within a value before encoding it, and
+// assigns a label to each of them at its first occurrence while encoding.
+type refRegister struct {
+	count    map[refKey]int
+	names    map[refKey]string
+	labels   []Label
+	registry *Registry
+}
+
+func newRefRegister(v reflect.Value, registry *Registry) *refRegister {
+	r := &refRegister{
+		count:    make(map[refKey]int),
+		names:    make(map[refKey]string),
+		registry: registry,
+	}
+	r.walk(v)
+	return r
+}
+
+func (r *refRegister) walk(v reflect.Value) {
+	if !v.IsValid() || r.registry.isLeafType(v.Type()) {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		key := refKey{v.Pointer(), v.Type()}
+		if r.count[key]++; r.count[key] == 1 {
+			r.walk(v.Elem())
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			r.walk(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			r.walk(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			r.walk(v.MapIndex(k))
+		}
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			if fv, ok := fieldByIndex(v, f.index); ok && f.method == "" {
+				r.walk(fv)
+			}
+		}
+	}
+}
+
+func (r *refRegister) shared(v reflect.Value) bool {
+	return r != nil && r.count[refKey{v.Pointer(), v.Type()}] > 1
+}
+
+// register returns the label of a shared pointer and whether it has occurred
+// before. The label is empty if the pointer is not shared.
+func (r *refRegister) register(v reflect.Value) (string, bool) {
+	if !r.shared(v) {
+		return "", false
+	}
+	key := refKey{v.Pointer(), v.Type()}
+	if name, ok := r.names[key]; ok {
+		return name, true
+	}
+	name := strconv.Itoa(len(r.labels) + 1)
+	r.names[key] = name
+	r.labels = append(r.labels, Label{Name: name, Addr: key.addr})
+	return name, false
+}
+
+func (enc *Encoder) marshalPtr(v reflect.Value) (core.Node, error) {
+	label, seen := enc.refs.register(v)
+	if seen {
+		return core.Node{Value: label, IsReference: true}, nil
+	}
+	node, err := enc.marshalNode(v.Elem())
+	if err != nil || label == "" {
+		return node, err
+	}
+	node.Annotations = append([]string{" ^" + label}, node.Annotations...)
+	return node, nil
+}
+
+// labelOf returns the label of a node annotated with "# ^label".
+func labelOf(node core.Node) (string, bool) {
+	for _, a := range node.Annotations {
+		if a = strings.TrimSpace(a); strings.HasPrefix(a, "^") {
+			return a[1:], true
+		}
+	}
+	return "", false
+}
+
+func isLabelled(list core.List) bool {
+	if len(list) != 1 {
+		return false
+	}
+	_, ok := labelOf(list[0])
+	return ok || list[0].IsReference
+}
+
+func (dec *Decoder) unmarshalPtr(node core.Node, v reflect.Value) error {
+	if node.IsReference {
+		p, ok := dec.labels[node.Value]
+		if !ok {
+			return fmt.Errorf("undefined label ^%s", node.Value)
+		}
+		if p.Type() != v.Type() {
+			return fmt.Errorf("label ^%s of %v cannot be assigned to %v", node.Value, p.Type(), v.Type())
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	if label, ok := labelOf(node); ok {
+		if dec.labels == nil {
+			dec.labels = make(map[string]reflect.Value)
+		}
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		dec.labels[label] = p
+	}
+	return dec.unmarshalNode(node, v.Elem())
+}
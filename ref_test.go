@@ -0,0 +1,142 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalWithInfo(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	type graph struct {
+		A, B *node
+		C, D *string
+	}
+	a := &node{Name: "a"}
+	a.Next = a
+	s := ns("s")
+	buf, info, err := MarshalWithInfo(graph{A: a, B: a, C: s, D: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `A:
+	# ^1
+	_
+		Name:
+			a
+		Next:
+			^1
+B:
+	^1
+C:
+	# ^2
+	s
+D:
+	^2`
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+	}
+	labels := []Label{
+		{Name: "1", Addr: reflect.ValueOf(a).Pointer()},
+		{Name: "2", Addr: reflect.ValueOf(s).Pointer()},
+	}
+	if !reflect.DeepEqual(info.Labels, labels) {
+		t.Fatalf("expect %v but got %v", labels, info.Labels)
+	}
+
+	var g graph
+	if err := Unmarshal(buf, &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.A != g.B || g.A.Next != g.A || g.C != g.D || *g.C != "s" {
+		t.Fatalf("shared pointers are not restored: %+v", g)
+	}
+
+	buf, info, err = MarshalWithInfo(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "nil" || len(info.Labels) != 0 {
+		t.Fatalf("expect nil without labels but got %s, %v", buf, info.Labels)
+	}
+}
+
+func TestLabelError(t *testing.T) {
+	for i, testcase := range []struct {
+		text  string
+		value interface{}
+	}{
+		{"^1", new([]*string)},
+		{"# ^1\na\n^1", new([]string)},
+	} {
+		if err := Unmarshal([]byte(testcase.text), testcase.value); err == nil {
+			t.Fatalf("testcase %d: expect error but got nil", i)
+		}
+	}
+}
+
+func TestLabelOrder(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	graph := func() map[string]*node {
+		m := make(map[string]*node)
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			n := &node{Name: name}
+			n.Next = n
+			m[name] = n
+			m[name+"2"] = n
+		}
+		return m
+	}
+	expected, err := Marshal(graph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		buf, err := Marshal(graph())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != string(expected) {
+			t.Fatalf("expect identical output \n%s\n    but got \n%s", expected, buf)
+		}
+	}
+	var m map[string]*node
+	if err := Unmarshal(expected, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != m["a2"] || m["a"].Next != m["a"] {
+		t.Fatalf("shared pointers are not restored: %v", m)
+	}
+}
+
+func TestLabelSortedFields(t *testing.T) {
+	type node struct {
+		Name string
+	}
+	type pair struct {
+		B, A *node
+	}
+	p := &node{"x"}
+	for i, c := range []*Codec{{SortFields: true}, {Canonical: true}} {
+		buf, err := c.Marshal(pair{p, p})
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		expected := "A:\n\t# ^1\n\t_\n\t\tName:\n\t\t\tx\nB:\n\t^1"
+		if string(buf) != expected {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, expected, buf)
+		}
+		var v pair
+		if err := c.Unmarshal(buf, &v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v.A != v.B || *v.A != *p {
+			t.Fatalf("testcase %d: shared pointer is not restored: %#v", i, v)
+		}
+	}
+}
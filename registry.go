@@ -0,0 +1,127 @@
+package teff
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Registry holds the registered enums, types, codecs, tuples and positionals.
+// The package functions such as RegisterEnum register into the global registry
+// consulted by all Encoders and Decoders. A Registry set by SetRegistry, e.g.
+// the Registry of a Codec, is consulted before the global registry, so that its
+// registrations are private to the Encoders and Decoders using it.
+//
+// A Registry is safe for concurrent use by multiple goroutines.
+type Registry struct {
+	mu          sync.RWMutex
+	enums       map[reflect.Type]*enum
+	typeTypes   map[string]reflect.Type
+	typeNames   map[reflect.Type]string
+	codecs      map[reflect.Type]codec
+	tuples      map[reflect.Type]bool
+	positionals map[reflect.Type]bool
+}
+
+var globalRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		enums:       make(map[reflect.Type]*enum),
+		typeTypes:   make(map[string]reflect.Type),
+		typeNames:   make(map[reflect.Type]string),
+		codecs:      make(map[reflect.Type]codec),
+		tuples:      make(map[reflect.Type]bool),
+		positionals: make(map[reflect.Type]bool),
+	}
+}
+
+// SetRegistry sets the registry consulted before the global one.
+func (enc *Encoder) SetRegistry(r *Registry) {
+	enc.registry = r
+}
+
+// SetRegistry sets the registry consulted before the global one.
+func (dec *Decoder) SetRegistry(r *Registry) {
+	dec.registry = r
+}
+
+// chain returns the registries to consult in order, a nil r means only the
+// global registry.
+func (r *Registry) chain() []*Registry {
+	if r == nil || r == globalRegistry {
+		return []*Registry{globalRegistry}
+	}
+	return []*Registry{r, globalRegistry}
+}
+
+func (r *Registry) lookupEnum(t reflect.Type) (*enum, bool) {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		e, ok := r.enums[t]
+		r.mu.RUnlock()
+		if ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) lookupTypeName(t reflect.Type) (string, bool) {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		name, ok := r.typeNames[t]
+		r.mu.RUnlock()
+		if ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (r *Registry) lookupType(name string) (reflect.Type, bool) {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		t, ok := r.typeTypes[name]
+		r.mu.RUnlock()
+		if ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) lookupCodec(t reflect.Type) (codec, bool) {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		c, ok := r.codecs[t]
+		r.mu.RUnlock()
+		if ok {
+			return c, true
+		}
+	}
+	return codec{}, false
+}
+
+func (r *Registry) isTuple(t reflect.Type) bool {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		ok := r.tuples[t]
+		r.mu.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) isPositional(t reflect.Type) bool {
+	for _, r := range r.chain() {
+		r.mu.RLock()
+		ok := r.positionals[t]
+		r.mu.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
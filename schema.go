@@ -0,0 +1,199 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// ValidateSchema validates the document doc against the schema, both in TEFF.
+// The validation errors are returned as a slice, and the error is non-nil only
+// if either of the inputs cannot be parsed or the schema is invalid.
+//
+// A schema describes a list of the document:
+//
+//   - A type name, i.e. string, int, bool or any, describes a single value of
+//     that type, where any also accepts a composite value.
+//   - "[]" with an indented schema describes a list of elements of that schema,
+//     where a composite element is under an anonymous parent "_".
+//   - Keys suffixed with ":" and followed by indented schemas describe a map or
+//     a struct. A key is required unless it is suffixed with "?:", and keys not
+//     in the schema are not allowed.
+//
+// e.g.
+//
+//	name:
+//		string
+//	port?:
+//		int
+//	tags:
+//		[]
+//			string
+func ValidateSchema(doc, schema []byte) ([]error, error) {
+	docList, err := core.Parse(bytes.NewReader(doc))
+	if err != nil {
+		return nil, err
+	}
+	schemaList, err := core.Parse(bytes.NewReader(schema))
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{}
+	if err := v.validateList(docList, schemaList, ""); err != nil {
+		return nil, err
+	}
+	return v.errs, nil
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", pathOrRoot(path), fmt.Sprintf(format, args...)))
+}
+
+// validateList validates a list of the document, it only returns an error if
+// the schema is invalid.
+func (v *validator) validateList(doc, schema core.List, path string) error {
+	if isObjectSchema(schema) {
+		return v.validateObject(doc, schema, path)
+	}
+	if len(schema) != 1 {
+		return fmt.Errorf("invalid schema at %s", pathOrRoot(path))
+	}
+	switch schema[0].Value {
+	case "[]":
+		if len(schema[0].List) == 0 {
+			return fmt.Errorf("missing element schema at %s", pathOrRoot(path))
+		}
+		for i, node := range doc {
+			if err := v.validateNode(node, schema[0].List, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "any":
+		return nil
+	}
+	if len(doc) != 1 {
+		if err := checkTypeName(schema[0].Value, path); err != nil {
+			return err
+		}
+		v.errorf(path, "expect a single %s but got %d values", schema[0].Value, len(doc))
+		return nil
+	}
+	return v.validateNode(doc[0], schema, path)
+}
+
+// validateNode validates a node of the document as an element of a list.
+func (v *validator) validateNode(node core.Node, schema core.List, path string) error {
+	if isObjectSchema(schema) || len(schema) == 1 && schema[0].Value == "[]" {
+		if node.Value != "_" || node.IsReference {
+			v.errorf(path, "expect a composite value but got %s", node.Value)
+			return nil
+		}
+		return v.validateList(node.List, schema, path)
+	}
+	if len(schema) != 1 {
+		return fmt.Errorf("invalid schema at %s", pathOrRoot(path))
+	}
+	typ := schema[0].Value
+	if err := checkTypeName(typ, path); err != nil {
+		return err
+	}
+	if typ == "any" {
+		return nil
+	}
+	if _, ok := blockString(node); ok && typ == "string" {
+		return nil
+	}
+	if node.IsReference || len(node.List) > 0 {
+		v.errorf(path, "expect %s but got a composite value", typ)
+		return nil
+	}
+	switch typ {
+	case "int":
+		if _, err := strconv.Atoi(node.Value); err != nil {
+			v.errorf(path, "expect int but got %s", node.Value)
+		}
+	case "bool":
+		if node.Value != "true" && node.Value != "false" {
+			v.errorf(path, "expect bool but got %s", node.Value)
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateObject(doc, schema core.List, path string) error {
+	values := make(map[string]core.List)
+	for _, node := range doc {
+		key, value, ok := splitKeyValue(node)
+		if !ok {
+			v.errorf(path, "expect a key but got %s", node.Value)
+			continue
+		}
+		if _, ok := values[key]; ok {
+			v.errorf(path, "duplicate key %s", key)
+			continue
+		}
+		values[key] = value
+	}
+	keys := make(map[string]bool)
+	for _, node := range schema {
+		key := strings.TrimSuffix(node.Value, ":")
+		optional := strings.HasSuffix(key, "?")
+		key = strings.TrimSuffix(key, "?")
+		keys[key] = true
+		value, ok := values[key]
+		if !ok {
+			if !optional {
+				v.errorf(path, "missing required key %s", key)
+			}
+			continue
+		}
+		if err := v.validateList(value, node.List, joinPath(path, key)); err != nil {
+			return err
+		}
+	}
+	for _, node := range doc {
+		if key, _, ok := splitKeyValue(node); ok && !keys[key] {
+			v.errorf(path, "unknown key %s", key)
+		}
+	}
+	return nil
+}
+
+func isObjectSchema(schema core.List) bool {
+	for _, node := range schema {
+		if !strings.HasSuffix(node.Value, ":") || node.IsReference {
+			return false
+		}
+	}
+	return len(schema) > 0
+}
+
+func checkTypeName(typ, path string) error {
+	switch typ {
+	case "string", "int", "bool", "any":
+		return nil
+	}
+	return fmt.Errorf("unknown type %s in schema at %s", typ, pathOrRoot(path))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "^"
+	}
+	return path
+}
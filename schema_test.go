@@ -0,0 +1,67 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := `name:
+	string
+port?:
+	int
+debug?:
+	bool
+tags:
+	[]
+		string
+servers:
+	[]
+		host:
+			string
+		weight?:
+			int
+extra?:
+	any`
+	for i, testcase := range []struct {
+		doc  string
+		errs []string
+	}{
+		{"name:\n\ta\nport:\n\t80\ntags:\n\tx\n\ty\nservers:\n\t_\n\t\thost:\n\t\t\th1\n\t\tweight: 2", nil},
+		{"name:\n\t|\n\t\ta\n\t\tb\ntags:\nservers:\nextra:\n\t_\n\t\tk:\n\t\t\tv", nil},
+		{"port:\n\tx\ndebug:\n\tyes\ntags:\n\t_\n\t\tx\nservers:\n\th1\n\t_\n\t\tweight:\n\t\t\t1\nother:\n\t1", []string{
+			"^: missing required key name",
+			"port: expect int but got x",
+			"debug: expect bool but got yes",
+			"tags[0]: expect string but got a composite value",
+			"servers[0]: expect a composite value but got h1",
+			"servers[1]: missing required key host",
+			"^: unknown key other",
+		}},
+		{"name:\n\ta\n\tb\ntags:\nservers:", []string{"name: expect a single string but got 2 values"}},
+	} {
+		errs, err := ValidateSchema([]byte(testcase.doc), []byte(schema))
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		if !reflect.DeepEqual(msgs, testcase.errs) {
+			t.Fatalf("testcase %d: expect %q but got %q", i, testcase.errs, msgs)
+		}
+	}
+}
+
+func TestInvalidSchema(t *testing.T) {
+	for i, schema := range []string{
+		"a:\n\tfloat",
+		"a:\n\t[]",
+		"a:\n\tstring\n\tint",
+	} {
+		if _, err := ValidateSchema([]byte("a:\n\t1"), []byte(schema)); err == nil {
+			t.Fatalf("testcase %d: expect error for invalid schema", i)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package teff
+
+import "h12.io/teff/core"
+
+// redacted is the placeholder encoded in place of the value of a field tagged
+// with option secret, e.g. `teff:"password,secret"`, so that a value can be
+// logged or dumped safely. A positional or tuple type cannot have a secret
+// field, since its values have no keys to redact by.
+const redacted = "***"
+
+// SetSkipRedacted sets whether a secret field of the redacted placeholder is
+// skipped as if it were absent, rather than failing the decoding, so that a
+// redacted dump can be decoded without loading the placeholder as a value.
+func (dec *Decoder) SetSkipRedacted(skip bool) {
+	dec.skipRedacted = skip
+}
+
+func isRedacted(list core.List) bool {
+	return len(list) == 1 && list[0].Value == redacted && len(list[0].List) == 0 && !list[0].IsReference
+}
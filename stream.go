@@ -0,0 +1,44 @@
+package teff
+
+import (
+	"io"
+
+	"h12.io/teff/core"
+)
+
+// DecodeEach decodes a top-level list from r element by element, calling fn
+// with the index of each element and a decoder of the element, so that a huge
+// list is processed without holding all of it in memory. It stops at the first
+// error returned by fn.
+func DecodeEach(r io.Reader, fn func(int, *Decoder) error) error {
+	return NewDecoder(r).DecodeEach(fn)
+}
+
+// DecodeEach is like the function DecodeEach but with the options of dec. The
+// decoder passed to fn shares the options of dec and decodes only the element,
+// e.g. by calling its Decode method once.
+func (dec *Decoder) DecodeEach(fn func(int, *Decoder) error) error {
+	if err := checkBoolTokens(dec.trues, dec.falses, dec.nilToken); err != nil {
+		return err
+	}
+	r, err := dec.reader()
+	if err != nil {
+		return err
+	}
+	scanner := dec.newScanner(r)
+	i := 0
+	err = core.ParseEach(scanner, func(node core.Node) error {
+		elem := *dec
+		elem.list = core.List{node}
+		if node.Value == "_" && !node.IsReference {
+			elem.list = append(core.List{}, node.List...)
+		}
+		if err := fn(i, &elem); err != nil {
+			return err
+		}
+		i++
+		return nil
+	})
+	dec.errs = append(dec.errs, scanner.Errors()...)
+	return err
+}
@@ -0,0 +1,77 @@
+package teff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEach(t *testing.T) {
+	const n = 100000
+	r, w := io.Pipe()
+	go func() {
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, "_\n\tName: server%d\n\tPort: %d\n", i, i)
+		}
+		w.Close()
+	}()
+	count := 0
+	err := DecodeEach(r, func(i int, dec *Decoder) error {
+		var s struct {
+			Name string
+			Port int
+		}
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		if i != count || s.Name != fmt.Sprintf("server%d", i) || s.Port != i {
+			return fmt.Errorf("unexpected element %d: %v", i, s)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("expect %d elements but got %d", n, count)
+	}
+}
+
+func TestDecodeEachScalar(t *testing.T) {
+	var a []int
+	err := DecodeEach(strings.NewReader("1\n2\n\n3"), func(i int, dec *Decoder) error {
+		var v int
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		a = append(a, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(a) != "[1 2 3]" {
+		t.Fatalf("expect [1 2 3] but got %v", a)
+	}
+}
+
+func TestDecodeEachStop(t *testing.T) {
+	errStop := errors.New("stop")
+	count := 0
+	err := DecodeEach(strings.NewReader("1\n2\n3"), func(i int, dec *Decoder) error {
+		count++
+		if i == 1 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("expect %v but got %v", errStop, err)
+	}
+	if count != 2 {
+		t.Fatalf("expect 2 calls but got %d", count)
+	}
+}
@@ -0,0 +1,45 @@
+package teff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type level uint
+
+func (l level) String() string {
+	return fmt.Sprintf("level %d", uint(l))
+}
+
+type mask uint8
+
+func (m *mask) String() string {
+	return fmt.Sprintf("%08b", uint8(*m))
+}
+
+func TestStringerFallback(t *testing.T) {
+	type reading struct {
+		Level level
+		Mask  mask
+		Count int
+	}
+	r := reading{Level: 2, Mask: 5, Count: 3}
+	if _, err := Marshal(r); err == nil {
+		t.Fatal("expect error without the fallback")
+	}
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetStringerFallback(true)
+	if err := enc.Encode(&r); err != nil {
+		t.Fatal(err)
+	}
+	text := "Level:\n\tlevel 2\nMask:\n\t\"00000101\"\nCount:\n\t3"
+	if w.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, w.String())
+	}
+	var decoded reading
+	if err := Unmarshal(w.Bytes(), &decoded); err == nil {
+		t.Fatal("expect error when decoding a Stringer encoded value")
+	}
+}
@@ -0,0 +1,453 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+type field struct {
+	name       string
+	tagged     bool
+	index      []int
+	timeLayout string
+	unit       string
+	comments   bool
+	method     string
+	required   bool
+	secret     bool
+	jsonName   string // name in the json tag of an untagged field, or "-"
+}
+
+var stringsType = reflect.TypeOf([]string(nil))
+
+// SelectFields sets a function selecting the struct fields to encode by their
+// encoded names, e.g. to redact secrets. All fields are encoded by default.
+func (enc *Encoder) SelectFields(selectField func(name string) bool) {
+	enc.selectField = selectField
+}
+
+// A field of type []string tagged with option comments holds the annotations
+// at the top of the struct block, i.e. the annotations preceding the first
+// field. The comments are not encoded if no other field is encoded.
+func (enc *Encoder) marshalStruct(v reflect.Value) (core.List, error) {
+	if enc.registry.isPositional(v.Type()) {
+		return enc.marshalPositional(v)
+	}
+	fields := structFields(v.Type())
+	if enc.jsonTags {
+		fields = applyJSONTags(fields)
+	}
+	if enc.sortFields || enc.canonical {
+		// sorted before marshalling, so that the labels are numbered in the
+		// order of the output.
+		fields = append([]field(nil), fields...)
+		sort.SliceStable(fields, func(i, j int) bool {
+			return quoteKey(fields[i].encodedName(enc.fieldNamer)) < quoteKey(fields[j].encodedName(enc.fieldNamer))
+		})
+	}
+	list := make(core.List, 0, len(fields))
+	var comments []string
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if f.comments {
+			comments = fv.Interface().([]string)
+			continue
+		}
+		name := f.encodedName(enc.fieldNamer)
+		if enc.selectField != nil && !enc.selectField(name) {
+			continue
+		}
+		if f.method != "" && !f.secret {
+			mv, err := callMethod(v, f.method)
+			if err != nil {
+				return nil, err
+			}
+			value, err := enc.marshalList(mv)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, enc.keyValue(quoteKey(name), mv.Type(), value))
+			continue
+		}
+		value, err := enc.marshalField(f, fv)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, enc.keyValue(quoteKey(name), fv.Type(), value))
+	}
+	if enc.align {
+		alignValues(list)
+	}
+	if len(comments) > 0 && len(list) > 0 {
+		list[0].Annotations = append(append([]string{}, comments...), list[0].Annotations...)
+	}
+	return list, nil
+}
+
+func (dec *Decoder) unmarshalStruct(list core.List, v reflect.Value) error {
+	if dec.registry.isPositional(v.Type()) {
+		return dec.unmarshalPositional(list, v)
+	}
+	list, err := dec.pairList(list)
+	if err != nil {
+		return err
+	}
+	fields := structFields(v.Type())
+	if dec.jsonTags {
+		fields = applyJSONTags(fields)
+	}
+	present := make(map[string]bool)
+	if f, ok := commentsField(fields); ok && len(list) > 0 && len(list[0].Annotations) > 0 {
+		comments := append([]string{}, list[0].Annotations...)
+		allocFieldByIndex(v, f.index).Set(reflect.ValueOf(comments))
+		present[f.name] = true
+	}
+	for _, node := range list {
+		name, value, ok := splitKeyValue(node)
+		if !ok {
+			continue
+		}
+		f, ok := dec.findField(fields, unquoteKey(name))
+		if !ok {
+			continue
+		}
+		if f.secret && isRedacted(value) {
+			if dec.skipRedacted {
+				continue
+			}
+			return fmt.Errorf("redacted secret %s cannot be decoded", f.name)
+		}
+		if err := dec.unmarshalField(f, value, allocFieldByIndex(v, f.index)); err != nil {
+			if e, ok := err.(*missingFieldError); ok {
+				e.path = f.name + "." + e.path
+			}
+			return err
+		}
+		present[f.name] = true
+	}
+	for _, f := range fields {
+		if f.required && !present[f.name] {
+			return &missingFieldError{path: f.name}
+		}
+	}
+	if !dec.merge {
+		resetFields(v, fields, present)
+	}
+	return nil
+}
+
+// missingFieldError reports a required field absent from the input, with the
+// dotted path of the field from the outermost struct.
+type missingFieldError struct {
+	path string
+}
+
+func (e *missingFieldError) Error() string {
+	return "missing required field " + e.path
+}
+
+// resetFields sets the fields absent from the input to zero, so that decoding
+// into a reused value keeps nothing from the previous decoding.
+func resetFields(v reflect.Value, fields []field, present map[string]bool) {
+	for _, f := range fields {
+		if present[f.name] || f.method != "" {
+			continue
+		}
+		if fv, ok := fieldByIndex(v, f.index); ok {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+}
+
+func (enc *Encoder) marshalField(f field, v reflect.Value) (core.List, error) {
+	if f.secret {
+		return core.List{{Value: redacted}}, nil
+	}
+	if f.timeLayout != "" && v.Type() == timeType {
+		node, err := enc.marshalTime(v, f.timeLayout)
+		if err != nil {
+			return nil, err
+		}
+		return core.List{node}, nil
+	}
+	if f.unit != "" {
+		node, err := marshalUnit(v, f.unit)
+		if err != nil {
+			return nil, err
+		}
+		return core.List{node}, nil
+	}
+	return enc.marshalList(v)
+}
+
+func (dec *Decoder) unmarshalField(f field, list core.List, v reflect.Value) error {
+	if f.timeLayout != "" && v.Type() == timeType {
+		if len(list) != 1 {
+			return fmt.Errorf("expect a single value of %v but got %d", v.Type(), len(list))
+		}
+		return unmarshalTime(list[0], v, f.timeLayout)
+	}
+	if f.unit != "" {
+		if len(list) != 1 {
+			return fmt.Errorf("expect a single value of %v but got %d", v.Type(), len(list))
+		}
+		return dec.unmarshalUnit(list[0], v, f.unit)
+	}
+	return dec.unmarshalList(list, v)
+}
+
+// encodedName returns the name of f transformed by namer unless the name is
+// specified by a tag.
+func (f field) encodedName(namer func(string) string) string {
+	if f.tagged || namer == nil {
+		return f.name
+	}
+	return namer(f.name)
+}
+
+// quoteKey quotes the name of a struct field as a string map key, e.g. a name
+// starting with a space or looking like a number.
+func quoteKey(name string) string {
+	if !isRawString(name) || isLiteral(name) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// unquoteKey returns the name of a quoted or raw key.
+func unquoteKey(key string) string {
+	if key != "" && (key[0] == '"' || key[0] == '`') {
+		return unquote(key)
+	}
+	return key
+}
+
+// findField returns the field of the name, preferring an exact match to a
+// case-insensitive one.
+func (dec *Decoder) findField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.encodedName(dec.fieldNamer) == name && !f.comments && f.method == "" {
+			return f, true
+		}
+	}
+	if dec.fold {
+		for _, f := range fields {
+			if strings.EqualFold(f.encodedName(dec.fieldNamer), name) && !f.comments && f.method == "" {
+				return f, true
+			}
+		}
+	}
+	return field{}, false
+}
+
+func commentsField(fields []field) (field, bool) {
+	for _, f := range fields {
+		if f.comments {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// fieldByIndex returns the nested field of v, it fails if an embedded pointer
+// on the path is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			v = indirect(v)
+			if v.Kind() == reflect.Ptr {
+				return reflect.Value{}, false
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// allocFieldByIndex returns the nested field of v, and allocates the embedded
+// pointers on the path only when they are actually visited.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			v = allocIndirect(v)
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// structFields returns the fields of struct type t in declaration order,
+// including fields promoted from embedded structs. A promoted field is hidden
+// by a field with the same name at a shallower depth, and fields with the same
+// name at the same depth hide each other.
+func structFields(t reflect.Type) []field {
+	all := appendFields(nil, t, nil)
+	depth := make(map[string]int)
+	count := make(map[string]int)
+	for _, f := range all {
+		d, ok := depth[f.name]
+		if !ok || len(f.index) < d {
+			depth[f.name] = len(f.index)
+			count[f.name] = 1
+		} else if len(f.index) == d {
+			count[f.name]++
+		}
+	}
+	fields := make([]field, 0, len(all))
+	for _, f := range all {
+		if len(f.index) == depth[f.name] && count[f.name] == 1 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func appendFields(fields []field, t reflect.Type, index []int) []field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("teff")
+		if tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if sf.PkgPath != "" && sf.Type.Kind() == reflect.Ptr {
+					continue
+				}
+				fields = appendFields(fields, ft, fieldIndex)
+				continue
+			}
+		}
+		method := opts.value(methodOption)
+		if sf.PkgPath != "" && method == "" {
+			continue
+		}
+		tagged := name != ""
+		var jsonName string
+		if !tagged {
+			name = sf.Name
+			jsonName, _ = parseTag(sf.Tag.Get("json"))
+		}
+		fields = append(fields, field{
+			name:       name,
+			tagged:     tagged,
+			index:      fieldIndex,
+			timeLayout: opts.timeLayout(),
+			unit:       opts.value(unitOption),
+			comments:   opts.contains("comments") && sf.Type == stringsType,
+			required:   opts.contains("required"),
+			secret:     opts.contains("secret"),
+			method:     method,
+			jsonName:   jsonName,
+		})
+	}
+	return fields
+}
+
+// applyJSONTags returns the fields named by their json tags if they have no
+// teff tags, without the fields tagged json:"-".
+func applyJSONTags(fields []field) []field {
+	result := make([]field, 0, len(fields))
+	for _, f := range fields {
+		switch f.jsonName {
+		case "":
+		case "-":
+			continue
+		default:
+			f.name, f.tagged = f.jsonName, true
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// tagOptions is the comma separated options following the name in a teff
+// struct tag.
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// contains returns true if option is one of the options before timefmt.
+func (o tagOptions) contains(option string) bool {
+	_, ok := o.find(func(opt string) bool { return opt == option })
+	return ok
+}
+
+// value returns the value of an option in the form of prefix followed by the
+// value, e.g. method=Name, before timefmt.
+func (o tagOptions) value(prefix string) string {
+	opt, _ := o.find(func(opt string) bool { return strings.HasPrefix(opt, prefix) })
+	return strings.TrimPrefix(opt, prefix)
+}
+
+func (o tagOptions) find(match func(string) bool) (string, bool) {
+	for s := string(o); s != ""; {
+		opt := s
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			opt, s = s[:i], s[i+1:]
+		} else {
+			s = ""
+		}
+		if strings.HasPrefix(opt, timeLayoutOption) {
+			return "", false
+		}
+		if match(opt) {
+			return opt, true
+		}
+	}
+	return "", false
+}
+
+// methodOption specifies a method whose result is encoded as the value of the
+// field, the field itself is ignored and usually declared as a blank field,
+// e.g. _ struct{} `teff:"full_name,method=FullName"`. The field is not decoded.
+const methodOption = "method="
+
+// callMethod calls the method of v that must take no arguments and return a
+// single value.
+func callMethod(v reflect.Value, name string) (reflect.Value, error) {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	m := v.Addr().MethodByName(name)
+	if !m.IsValid() {
+		return reflect.Value{}, fmt.Errorf("method %s of %v not found", name, v.Type())
+	}
+	if t := m.Type(); t.NumIn() != 0 || t.NumOut() != 1 {
+		return reflect.Value{}, fmt.Errorf("method %s of %v must take no arguments and return a single value", name, v.Type())
+	}
+	return m.Call(nil)[0], nil
+}
+
+const timeLayoutOption = "timefmt="
+
+// timeLayout returns the layout of option timefmt. It must be the last option
+// because the layout extends to the end of the tag and may contain commas.
+func (o tagOptions) timeLayout() string {
+	s := "," + string(o)
+	if i := strings.Index(s, ","+timeLayoutOption); i >= 0 {
+		return s[i+1+len(timeLayoutOption):]
+	}
+	return ""
+}
@@ -0,0 +1,578 @@
+package teff
+
+import (
+	"bytes"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+type Base struct {
+	ID int
+}
+
+type Derived struct {
+	*Base
+	Name string
+}
+
+type ConfigBase struct {
+	Port int    `teff:"port"`
+	Host string `teff:"host"`
+}
+
+type Config struct {
+	ConfigBase
+	Name string
+}
+
+func TestStruct(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{struct{}{}, ""},
+		{struct {
+			I int
+			S string
+		}{1, "a"}, "I:\n\t1\nS:\n\ta"},
+		{struct {
+			I int
+			p int
+		}{1, 0}, "I:\n\t1"},
+		{struct{ P *int }{}, "P:\n\tnil"},
+		{[]struct{ I int }{{1}, {2}}, "_\n\tI:\n\t\t1\n_\n\tI:\n\t\t2"},
+		{Derived{&Base{1}, "a"}, "ID:\n\t1\nName:\n\ta"},
+		{Derived{nil, "a"}, "Name:\n\ta"},
+		{Config{ConfigBase{80, "h"}, "a"}, "port:\n\t80\nhost:\n\th\nName:\n\ta"},
+		{struct {
+			*ConfigBase
+			Port int `teff:"port"`
+		}{&ConfigBase{Host: "h"}, 1}, "host:\n\th\nport:\n\t1"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, string(buf))
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+}
+
+func TestEmbeddedPtrAlloc(t *testing.T) {
+	{
+		var d Derived
+		if err := Unmarshal([]byte("Name:\n\ta"), &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Base != nil {
+			t.Fatalf("expect nil embedded pointer but got %v", d.Base)
+		}
+	}
+	{
+		var d Derived
+		if err := Unmarshal([]byte("ID:\n\t1"), &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Base == nil || d.ID != 1 {
+			t.Fatalf("expect embedded pointer allocated with ID 1 but got %v", d.Base)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	type server struct {
+		Comments []string `teff:",comments"`
+		Host     string
+		Port     int
+	}
+	type config struct {
+		Doc    []string `teff:",comments"`
+		Server server
+	}
+	text := "#config file\n#version 1\nServer:\n\t# the main server\n\tHost:\n\t\texample.com\n\tPort:\n\t\t80"
+	var c config
+	if err := Unmarshal([]byte(text), &c); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		Doc: []string{"config file", "version 1"},
+		Server: server{
+			Comments: []string{" the main server"},
+			Host:     "example.com",
+			Port:     80,
+		},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expect %#v but got %#v", expected, c)
+	}
+	buf, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, string(buf))
+	}
+}
+
+func TestFieldNamer(t *testing.T) {
+	type server struct {
+		HostName string
+		HTTPPort int
+		Tagged   int `teff:"TaggedField"`
+	}
+	snakeCase := func(name string) string {
+		var rs []rune
+		for i, r := range name {
+			if unicode.IsUpper(r) {
+				if i > 0 && !unicode.IsUpper(rune(name[i-1])) {
+					rs = append(rs, '_')
+				}
+				r = unicode.ToLower(r)
+			}
+			rs = append(rs, r)
+		}
+		return string(rs)
+	}
+	s := server{HostName: "example.com", HTTPPort: 80, Tagged: 1}
+	text := "host_name:\n\texample.com\nhttpport:\n\t80\nTaggedField:\n\t1"
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFieldNamer(snakeCase)
+	if err := enc.Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf.String())
+	}
+	var decoded server
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetFieldNamer(snakeCase)
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != s {
+		t.Fatalf("expect %#v but got %#v", s, decoded)
+	}
+}
+
+func TestInline(t *testing.T) {
+	type server struct {
+		Host  string
+		Port  int
+		Note  string
+		Tags  []string
+		Attrs map[string]string
+		Next  *server
+	}
+	s := server{
+		Host:  "example.com",
+		Port:  80,
+		Note:  "a: b",
+		Tags:  []string{"x"},
+		Attrs: map[string]string{"k: v": "1", "l": "m:", "n": "o"},
+	}
+	text := "Host: example.com\nPort: 80\nNote: a: b\nTags:\n\tx\nAttrs:\n\tk: v:\n\t\t\"1\"\n\tl:\n\t\tm:\n\tn: o\nNext: nil"
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetInline(true)
+	if err := enc.Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf.String())
+	}
+	var decoded server
+	if err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, s) {
+		t.Fatalf("expect %#v but got %#v", s, decoded)
+	}
+}
+
+func TestJSONTags(t *testing.T) {
+	type server struct {
+		HostName string `json:"host_name"`
+		Port     int    `json:"port,omitempty" teff:"listen_port"`
+		Secret   string `json:"-"`
+		Tags     []string
+		Note     string `json:",omitempty"`
+	}
+	s := server{HostName: "a", Port: 80, Tags: []string{"x"}, Note: "n"}
+	c := &Codec{JSONTags: true}
+	buf, err := c.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "host_name:\n\ta\nlisten_port:\n\t80\nTags:\n\tx\nNote:\n\tn"
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	decoded := server{Secret: "kept"}
+	if err := c.Unmarshal([]byte(text+"\nSecret:\n\tb"), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if s.Secret = "kept"; !reflect.DeepEqual(decoded, s) {
+		t.Fatalf("expect %#v but got %#v", s, decoded)
+	}
+	if err := Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.HostName != "" {
+		t.Fatalf("expect json tags ignored by default but got %#v", decoded)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	type credential struct {
+		User     string
+		Password string `teff:"password,secret"`
+		Token    *int   `teff:",secret"`
+	}
+	token := 42
+	buf, err := Marshal(credential{"a", "p@ss", &token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "User:\n\ta\npassword:\n\t***\nToken:\n\t***"
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	var c credential
+	if err := Unmarshal(buf, &c); err == nil {
+		t.Fatal("expect error for a redacted secret")
+	}
+	dec := NewDecoder(bytes.NewReader(buf))
+	dec.SetSkipRedacted(true)
+	if err := dec.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (credential{User: "a"}); !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expect %#v but got %#v", expected, c)
+	}
+	if err := Unmarshal([]byte("password:\n\tp@ss\nToken:\n\t7"), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "p@ss" || *c.Token != 7 {
+		t.Fatalf("expect a secret decoded as is but got %#v", c)
+	}
+	buf, err = Marshal(session{User: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := "User:\n\ta\ntoken:\n\t***"; string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+}
+
+type session struct {
+	User string
+	_    struct{} `teff:"token,method=Token,secret"`
+}
+
+func (s session) Token() string {
+	return "s3cr3t"
+}
+
+func TestAlignValues(t *testing.T) {
+	type server struct {
+		Host     string
+		Port     int
+		Tags     []string
+		Attrs    map[string]string
+		Disabled bool
+	}
+	s := server{
+		Host:  "example.com",
+		Port:  80,
+		Tags:  []string{"x"},
+		Attrs: map[string]string{"k: v": "1", "long": "a", "n": "b: c"},
+	}
+	text := "Host:     example.com\nPort:     80\nTags:\n\tx\nAttrs:\n\tk: v:\n\t\t\"1\"\n\tlong: a\n\tn:    b: c\nDisabled: false"
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetInline(true)
+	enc.SetAlignValues(true)
+	if err := enc.Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf.String())
+	}
+	var decoded server
+	if err := Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, s) {
+		t.Fatalf("expect %#v but got %#v", s, decoded)
+	}
+
+	buf.Reset()
+	if err := enc.Encode(map[string]int{"a": 1, "bcd": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if text := "a:   1\nbcd: 2"; buf.String() != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf.String())
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	type server struct {
+		Host string
+		HOST string `teff:"host"`
+		Port int
+	}
+	text := "host:\n\ta\nPORT:\n\t80"
+	var s server
+	dec := NewDecoder(strings.NewReader(text))
+	dec.SetCaseInsensitive(true)
+	if err := dec.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	expected := server{HOST: "a", Port: 80}
+	if s != expected {
+		t.Fatalf("expect %#v but got %#v", expected, s)
+	}
+	s = server{}
+	if err := Unmarshal([]byte(text), &s); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (server{HOST: "a"}); s != expected {
+		t.Fatalf("expect %#v but got %#v", expected, s)
+	}
+}
+
+type person struct {
+	First string
+	Last  string
+	_     struct{} `teff:"full_name,method=FullName"`
+	_     struct{} `teff:"initials,method=Initials"`
+}
+
+func (p person) FullName() string {
+	return p.First + " " + p.Last
+}
+
+func (p *person) Initials() string {
+	return p.First[:1] + p.Last[:1]
+}
+
+type badMethod struct {
+	_ struct{} `teff:"x,method=Set"`
+}
+
+func (badMethod) Set(int) {}
+
+func TestMethodField(t *testing.T) {
+	p := person{First: "Ada", Last: "Lovelace"}
+	text := "First:\n\tAda\nLast:\n\tLovelace\nfull_name:\n\tAda Lovelace\ninitials:\n\tAL"
+	for _, v := range []interface{}{p, &p} {
+		buf, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != text {
+			t.Fatalf("expect \n%s\n    but got \n%s", text, string(buf))
+		}
+	}
+	var decoded person
+	if err := Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != p {
+		t.Fatalf("expect %#v but got %#v", p, decoded)
+	}
+	if err := CheckType(reflect.TypeOf(p)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Marshal(badMethod{}); err == nil {
+		t.Fatal("expect error for method with a wrong signature")
+	}
+	if err := CheckType(reflect.TypeOf(badMethod{})); err == nil {
+		t.Fatal("expect error for method with a wrong signature")
+	}
+}
+
+func TestLazyPtrField(t *testing.T) {
+	type sub struct {
+		Level int
+	}
+	type config struct {
+		Name string
+		Log  *sub
+		Auth *sub
+	}
+	for i, testcase := range []struct {
+		text      string
+		log, auth bool
+	}{
+		{"Name:\n\ta", false, false},
+		{"Log:\n\tLevel:\n\t\t1", true, false},
+		{"Log:\n\tLevel:\n\t\t1\nAuth:", true, true},
+		{"Auth:\n\tnil", false, false},
+	} {
+		var c config
+		if err := Unmarshal([]byte(testcase.text), &c); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if (c.Log != nil) != testcase.log || (c.Auth != nil) != testcase.auth {
+			t.Fatalf("testcase %d: expect Log %v and Auth %v allocated but got %#v", i, testcase.log, testcase.auth, c)
+		}
+		if testcase.log && c.Log.Level != 1 || testcase.auth && *c.Auth != (sub{}) {
+			t.Fatalf("testcase %d: unexpected value %#v", i, c)
+		}
+	}
+}
+
+func TestRequiredField(t *testing.T) {
+	type server struct {
+		Host string
+		Port int `teff:"port,required"`
+	}
+	type config struct {
+		Name   string `teff:"name,required"`
+		Server server
+	}
+	for i, testcase := range []struct {
+		text string
+		err  string
+	}{
+		{"name:\n\ta\nServer:\n\tport:\n\t\t80", ""},
+		{"Server:\n\tport:\n\t\t80", "missing required field name"},
+		{"name:\n\ta\nServer:\n\tHost:\n\t\th", "missing required field Server.port"},
+	} {
+		var c config
+		err := Unmarshal([]byte(testcase.text), &c)
+		if testcase.err == "" && err != nil || testcase.err != "" && (err == nil || err.Error() != testcase.err) {
+			t.Fatalf("testcase %d: expect error %q but got %v", i, testcase.err, err)
+		}
+	}
+}
+
+// sql.Null* types are encoded as plain structs, so both the value and Valid
+// round-trip.
+func TestNullString(t *testing.T) {
+	for i, testcase := range []struct {
+		value sql.NullString
+		text  string
+	}{
+		{sql.NullString{String: "a", Valid: true}, "String:\n\ta\nValid:\n\ttrue"},
+		{sql.NullString{}, "String:\n\t\"\"\nValid:\n\tfalse"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		s := sql.NullString{String: "x", Valid: true}
+		if err := Unmarshal(buf, &s); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if s != testcase.value {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, s)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	type db struct {
+		User     string
+		Password string
+	}
+	type config struct {
+		Name string
+		Port int
+		DB   db
+	}
+	c := config{"a", 80, db{"u", "secret"}}
+	for i, testcase := range []struct {
+		selectField func(string) bool
+		text        string
+	}{
+		{nil, "Name:\n\ta\nPort:\n\t80\nDB:\n\tUser:\n\t\tu\n\tPassword:\n\t\tsecret"},
+		{func(name string) bool { return name == "Name" || name == "Port" }, "Name:\n\ta\nPort:\n\t80"},
+		{func(name string) bool { return name != "Password" }, "Name:\n\ta\nPort:\n\t80\nDB:\n\tUser:\n\t\tu"},
+	} {
+		var w bytes.Buffer
+		enc := NewEncoder(&w)
+		enc.SelectFields(testcase.selectField)
+		if err := enc.Encode(c); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if w.String() != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, w.String())
+		}
+	}
+}
+
+func TestStructOf(t *testing.T) {
+	inner := reflect.StructOf([]reflect.StructField{
+		{Name: "Port", Type: reflect.TypeOf(0), Tag: `teff:"port"`},
+	})
+	typ := reflect.StructOf([]reflect.StructField{
+		{Name: "Host", Type: reflect.TypeOf(""), Tag: `teff:"host"`},
+		{Name: "Server", Type: inner},
+		{Name: "Tags", Type: reflect.TypeOf([]string{}), Tag: `teff:",omitempty"`},
+	})
+	text := "host:\n\ta\nServer:\n\tport:\n\t\t80\nTags:\n\tx\n\ty"
+	v := reflect.New(typ)
+	if err := Unmarshal([]byte(text), v.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	e := v.Elem()
+	if e.Field(0).String() != "a" || e.Field(1).Field(0).Int() != 80 || !reflect.DeepEqual(e.Field(2).Interface(), []string{"x", "y"}) {
+		t.Fatalf("unexpected value %#v", e.Interface())
+	}
+	buf, err := Marshal(e.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+}
+
+func TestQuotedFieldName(t *testing.T) {
+	type header struct {
+		ContentType string `teff:"content type"`
+		Version     int    `teff:"1"`
+		Padded      string `teff:" x"`
+		Hash        string `teff:"#a"`
+	}
+	value := header{"text/plain", 2, "y", "z"}
+	expected := "content type:\n\ttext/plain\n\"1\":\n\t2\n\" x\":\n\ty\n\"#a\":\n\tz"
+	buf, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, buf)
+	}
+	var v header
+	if err := Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v != value {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+	v = header{}
+	if err := Unmarshal([]byte("\"content type\": text/html"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.ContentType != "text/html" {
+		t.Fatalf("expect a quoted key to match but got %#v", v)
+	}
+}
@@ -0,0 +1,119 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"h12.io/teff/core"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func (enc *Encoder) marshalTime(v reflect.Value, layout string) (core.Node, error) {
+	t := v.Interface().(time.Time)
+	if enc.utc {
+		t = t.UTC()
+	}
+	s := t.Format(layout)
+	if !isRawString(s) {
+		s = strconv.Quote(s)
+	}
+	return core.Node{Value: s}, nil
+}
+
+// SetUTC sets whether a time.Time is converted to UTC before encoding, so that
+// the output does not depend on the location of the time. The default keeps
+// the location.
+func (enc *Encoder) SetUTC(utc bool) {
+	enc.utc = utc
+}
+
+func unmarshalTime(node core.Node, v reflect.Value, layout string) error {
+	t, err := time.Parse(layout, unquote(node.Value))
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func marshalDuration(v reflect.Value) (core.Node, error) {
+	return core.Node{Value: time.Duration(v.Int()).String()}, nil
+}
+
+func (dec *Decoder) unmarshalDuration(node core.Node, v reflect.Value) error {
+	parse := time.ParseDuration
+	if dec.duration != nil {
+		parse = dec.duration
+	}
+	d, err := parse(unquote(node.Value))
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(d))
+	return nil
+}
+
+// SetDurationParser sets the function parsing a time.Duration, the default is
+// time.ParseDuration. A time.Duration is always encoded by its String method.
+func (dec *Decoder) SetDurationParser(parse func(string) (time.Duration, error)) {
+	dec.duration = parse
+}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "nanosecond": time.Nanosecond, "nanoseconds": time.Nanosecond,
+	"us": time.Microsecond, "µs": time.Microsecond, "microsecond": time.Microsecond, "microseconds": time.Microsecond,
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// ParseVerboseDuration parses a duration either in the format accepted by
+// time.ParseDuration or as a sequence of numbers followed by case-insensitive
+// unit names separated by spaces or commas, e.g. "1 hour 30 minutes" or
+// "2 days, 3h". It can be set to a Decoder by SetDurationParser.
+func ParseVerboseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	fields := strings.Fields(strings.Replace(s, ",", " ", -1))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	for i := 0; i < len(fields); i++ {
+		num, unit := splitNumber(fields[i])
+		if unit == "" && i+1 < len(fields) {
+			i++
+			unit = fields[i]
+		}
+		n, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		u, ok := durationUnits[strings.ToLower(unit)]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q in duration %q", unit, s)
+		}
+		d += time.Duration(n * float64(u))
+	}
+	return d, nil
+}
+
+func splitNumber(s string) (num, rest string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.+-", r)
+	})
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,122 @@
+package teff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	type event struct {
+		Date     time.Time `teff:"date,timefmt=2006-01-02"`
+		Deadline time.Time `teff:",timefmt=Jan 2, 2006 at 3:04pm (MST)"`
+		Created  time.Time
+		Ignored  time.Time `teff:"-"`
+	}
+	e := event{
+		Date:     time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC),
+		Deadline: time.Date(2015, 3, 4, 17, 30, 0, 0, time.UTC),
+		Created:  time.Date(2015, 3, 4, 17, 30, 1, 5, time.UTC),
+	}
+	expected := "date:\n\t2015-03-04\nDeadline:\n\tMar 4, 2015 at 5:30pm (UTC)\nCreated:\n\t2015-03-04T17:30:01.000000005Z"
+	buf, err := Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expect \n%s\n    but got \n%s", expected, string(buf))
+	}
+	var d event
+	if err := Unmarshal(buf, &d); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Date.Equal(e.Date) || !d.Deadline.Equal(e.Deadline) || !d.Created.Equal(e.Created) {
+		t.Fatalf("expect %v but got %v", e, d)
+	}
+}
+
+func TestUTC(t *testing.T) {
+	type event struct {
+		Date    time.Time `teff:",timefmt=2006-01-02 15:04 MST"`
+		Created time.Time
+	}
+	loc := time.FixedZone("CST", 8*3600)
+	e := event{
+		Date:    time.Date(2015, 3, 4, 1, 30, 0, 0, loc),
+		Created: time.Date(2015, 3, 4, 1, 30, 1, 0, loc),
+	}
+	for _, testcase := range []struct {
+		utc      bool
+		expected string
+	}{
+		{false, "Date:\n\t2015-03-04 01:30 CST\nCreated:\n\t2015-03-04T01:30:01+08:00"},
+		{true, "Date:\n\t2015-03-03 17:30 UTC\nCreated:\n\t2015-03-03T17:30:01Z"},
+	} {
+		var w strings.Builder
+		enc := NewEncoder(&w)
+		enc.SetUTC(testcase.utc)
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != testcase.expected {
+			t.Fatalf("expect \n%s\n    but got \n%s", testcase.expected, w.String())
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	type config struct {
+		Timeout  time.Duration
+		Interval *time.Duration
+	}
+	interval := 90 * time.Second
+	c := config{Timeout: time.Hour + 30*time.Minute, Interval: &interval}
+	text := "Timeout:\n\t1h30m0s\nInterval:\n\t1m30s"
+	buf, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, string(buf))
+	}
+	var d config
+	if err := Unmarshal(buf, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Timeout != c.Timeout || d.Interval == nil || *d.Interval != interval {
+		t.Fatalf("expect %v but got %v", c, d)
+	}
+	if err := Unmarshal([]byte("Timeout:\n\t1 hour"), &d); err == nil {
+		t.Fatal("expect error for verbose duration by default")
+	}
+}
+
+func TestVerboseDuration(t *testing.T) {
+	for i, testcase := range []struct {
+		s string
+		d time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"1 hour", time.Hour},
+		{"1 hour 30 minutes", 90 * time.Minute},
+		{"2 days, 3h", 51 * time.Hour},
+		{"1.5 Hours", 90 * time.Minute},
+		{"500 ms", 500 * time.Millisecond},
+	} {
+		var d time.Duration
+		dec := NewDecoder(strings.NewReader(testcase.s))
+		dec.SetDurationParser(ParseVerboseDuration)
+		if err := dec.Decode(&d); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if d != testcase.d {
+			t.Fatalf("testcase %d: expect %v but got %v", i, testcase.d, d)
+		}
+	}
+	for _, s := range []string{"", "1 fortnight", "hour", "1 2"} {
+		if _, err := ParseVerboseDuration(s); err == nil {
+			t.Fatalf("expect error for %q", s)
+		}
+	}
+}
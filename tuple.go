@@ -0,0 +1,107 @@
+package teff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"h12.io/teff/core"
+)
+
+// RegisterTuple registers a struct type t of scalar fields to be encoded as a
+// single value of its fields separated by spaces, e.g. a Point{X, Y} as "3 4".
+// A string field containing spaces is quoted.
+func RegisterTuple(t reflect.Type) {
+	globalRegistry.RegisterTuple(t)
+}
+
+// RegisterTuple is like the function RegisterTuple but registers into r.
+func (r *Registry) RegisterTuple(t reflect.Type) {
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("teff: registering tuple of non-struct type %v", t))
+	}
+	for _, f := range structFields(t) {
+		ft := t.FieldByIndex(f.index).Type
+		if !r.isScalarType(ft) || ft.Kind() == reflect.Ptr || r.isLeafType(ft) || f.method != "" {
+			panic(fmt.Sprintf("teff: registering tuple %v with non-scalar field %s", t, f.name))
+		}
+		if f.secret {
+			panic(fmt.Sprintf("teff: registering tuple %v with secret field %s", t, f.name))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tuples[t] = true
+}
+
+func (enc *Encoder) marshalTuple(v reflect.Value) (core.Node, error) {
+	var values []string
+	for _, f := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			fv = reflect.Zero(v.Type().FieldByIndex(f.index).Type)
+		}
+		node, err := enc.marshalNode(fv)
+		if err != nil {
+			return core.Node{}, err
+		}
+		s := node.Value
+		if len(node.List) > 0 || strings.ContainsAny(s, " \t") && s[0] != '"' {
+			s = strconv.Quote(fv.String())
+		}
+		values = append(values, s)
+	}
+	return core.Node{Value: strings.Join(values, " ")}, nil
+}
+
+func (dec *Decoder) unmarshalTuple(node core.Node, v reflect.Value) error {
+	values, err := splitTuple(node.Value)
+	if err != nil {
+		return err
+	}
+	fields := structFields(v.Type())
+	if len(values) != len(fields) {
+		return fmt.Errorf("expect %d values of %v but got %d", len(fields), v.Type(), len(values))
+	}
+	for i, f := range fields {
+		if err := dec.unmarshalNode(core.Node{Value: values[i]}, allocFieldByIndex(v, f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitTuple splits s by spaces, except the spaces within a quoted string.
+func splitTuple(s string) ([]string, error) {
+	var values []string
+	for s = strings.TrimLeft(s, " \t"); s != ""; s = strings.TrimLeft(s, " \t") {
+		end := strings.IndexAny(s, " \t")
+		if s[0] == '"' || s[0] == '`' {
+			end = quoteEnd(s)
+			if end < 0 || end < len(s) && s[end] != ' ' && s[end] != '\t' {
+				return nil, fmt.Errorf("invalid quoted string in tuple: %s", s)
+			}
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		values = append(values, s[:end])
+		s = s[end:]
+	}
+	return values, nil
+}
+
+// quoteEnd returns the index after the closing quote of the quoted string at
+// the start of s, or -1 if it is not closed.
+func quoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && s[0] == '"':
+			i++
+		case s[i] == s[0]:
+			return i + 1
+		}
+	}
+	return -1
+}
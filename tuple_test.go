@@ -0,0 +1,69 @@
+package teff
+
+import (
+	"reflect"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+type label struct {
+	Text string
+	Size float64
+	Bold bool
+}
+
+func init() {
+	RegisterTuple(reflect.TypeOf(point{}))
+	RegisterTuple(reflect.TypeOf(label{}))
+}
+
+func TestTuple(t *testing.T) {
+	for i, testcase := range []struct {
+		value interface{}
+		text  string
+	}{
+		{point{3, 4}, "3 4"},
+		{[]point{{1, 2}, {-3, 4}}, "1 2\n-3 4"},
+		{struct{ P point }{point{3, 4}}, "P:\n\t3 4"},
+		{label{"a b", 1.5, true}, `"a b" 1.5 true`},
+		{label{"", 0, false}, `"" 0 false`},
+		{label{"a\nb", 0, false}, `"a\nb" 0 false`},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		newValue := newValueOf(testcase.value)
+		if err := Unmarshal(buf, newValue); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v := reflect.ValueOf(newValue).Elem().Interface(); !reflect.DeepEqual(v, testcase.value) {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+	var p point
+	for _, text := range []string{"3", "3 4 5", `"3 4`} {
+		if err := Unmarshal([]byte(text), &p); err == nil {
+			t.Fatalf("%s: expect error but got nil", text)
+		}
+	}
+}
+
+func TestTupleSecret(t *testing.T) {
+	type credential struct {
+		User     string
+		Password string `teff:"password,secret"`
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic for a secret field")
+		}
+	}()
+	RegisterTuple(reflect.TypeOf(credential{}))
+}
@@ -0,0 +1,107 @@
+package teff
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"h12.io/teff/core"
+)
+
+// unitOption specifies the unit of a signed integer field, which is encoded and
+// decoded in a human readable form:
+//   - unit=bytes: a size with a suffix of B, KB, MB, GB, TB or the binary KiB,
+//     MiB, GiB, TiB, e.g. 10MB, or a plain number of bytes.
+//   - unit=seconds: a duration as accepted by the duration parser, e.g. 5m, or
+//     a plain number of seconds. It must be whole seconds.
+const unitOption = "unit="
+
+type sizeUnit struct {
+	name string
+	size int64
+}
+
+// sizeUnits is in descending order of size.
+var sizeUnits = []sizeUnit{
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"KB", 1e3},
+	{"B", 1},
+}
+
+func checkUnit(unit string, t reflect.Type) error {
+	if unit != "bytes" && unit != "seconds" {
+		return fmt.Errorf("unknown unit %s", unit)
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		return fmt.Errorf("unit %s of non-int type %v", unit, t)
+	}
+	return nil
+}
+
+func marshalUnit(v reflect.Value, unit string) (core.Node, error) {
+	if err := checkUnit(unit, v.Type()); err != nil {
+		return core.Node{}, err
+	}
+	n := v.Int()
+	if unit == "seconds" {
+		if n > math.MaxInt64/int64(time.Second) || n < math.MinInt64/int64(time.Second) {
+			return core.Node{}, fmt.Errorf("%d seconds overflows a duration", n)
+		}
+		return core.Node{Value: (time.Duration(n) * time.Second).String()}, nil
+	}
+	for _, u := range sizeUnits {
+		if n != 0 && n%u.size == 0 {
+			return core.Node{Value: strconv.FormatInt(n/u.size, 10) + u.name}, nil
+		}
+	}
+	return core.Node{Value: "0B"}, nil
+}
+
+func (dec *Decoder) unmarshalUnit(node core.Node, v reflect.Value, unit string) error {
+	if err := checkUnit(unit, v.Type()); err != nil {
+		return err
+	}
+	s := unquote(node.Value)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return setUnit(v, n, s)
+	}
+	if unit == "seconds" {
+		var d time.Duration
+		if err := dec.unmarshalDuration(node, reflect.ValueOf(&d).Elem()); err != nil {
+			return err
+		}
+		if d%time.Second != 0 {
+			return fmt.Errorf("%v is not whole seconds", d)
+		}
+		return setUnit(v, int64(d/time.Second), s)
+	}
+	num, suffix := splitNumber(s)
+	for _, u := range sizeUnits {
+		if strings.EqualFold(strings.TrimSpace(suffix), u.name) {
+			n, err := strconv.ParseInt(num, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid size %s", s)
+			}
+			if n > math.MaxInt64/u.size || n < math.MinInt64/u.size {
+				return fmt.Errorf("size %s overflows %v", s, v.Type())
+			}
+			return setUnit(v, n*u.size, s)
+		}
+	}
+	return fmt.Errorf("invalid size %s", s)
+}
+
+func setUnit(v reflect.Value, n int64, s string) error {
+	if v.OverflowInt(n) {
+		return fmt.Errorf("%s overflows %v", s, v.Type())
+	}
+	v.SetInt(n)
+	return nil
+}
@@ -0,0 +1,95 @@
+package teff
+
+import (
+	"testing"
+)
+
+func TestUnit(t *testing.T) {
+	type limits struct {
+		MaxSize int `teff:"maxsize,unit=bytes"`
+		Timeout int `teff:"timeout,unit=seconds"`
+	}
+	for i, testcase := range []struct {
+		value limits
+		text  string
+	}{
+		{limits{10e6, 300}, "maxsize:\n\t10MB\ntimeout:\n\t5m0s"},
+		{limits{3 << 30, 5400}, "maxsize:\n\t3GiB\ntimeout:\n\t1h30m0s"},
+		{limits{1500, 1}, "maxsize:\n\t1500B\ntimeout:\n\t1s"},
+		{limits{0, 0}, "maxsize:\n\t0B\ntimeout:\n\t0s"},
+	} {
+		buf, err := Marshal(testcase.value)
+		if err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if string(buf) != testcase.text {
+			t.Fatalf("testcase %d: expect \n%s\n    but got \n%s", i, testcase.text, buf)
+		}
+		var v limits
+		if err := Unmarshal(buf, &v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v != testcase.value {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.value, v)
+		}
+	}
+	for i, testcase := range []struct {
+		text     string
+		expected limits
+	}{
+		{"maxsize: 2kb\ntimeout: 90", limits{2000, 90}},
+		{"maxsize: 4096\ntimeout: 2h", limits{4096, 7200}},
+		{"maxsize: 1 MiB", limits{1 << 20, 0}},
+	} {
+		var v limits
+		if err := Unmarshal([]byte(testcase.text), &v); err != nil {
+			t.Fatalf("testcase %d: %v", i, err)
+		}
+		if v != testcase.expected {
+			t.Fatalf("testcase %d: expect %#v but got %#v", i, testcase.expected, v)
+		}
+	}
+	for i, text := range []string{"maxsize: 10XB", "maxsize: 1.5GB", "timeout: 1500ms", "maxsize: 20000000TB"} {
+		var v limits
+		if err := Unmarshal([]byte(text), &v); err == nil {
+			t.Fatalf("testcase %d: expect error for %s", i, text)
+		}
+	}
+}
+
+func TestUnitSizedInt(t *testing.T) {
+	type limits struct {
+		MaxSize int64 `teff:"maxsize,unit=bytes"`
+		Timeout int32 `teff:"timeout,unit=seconds"`
+		Small   int16 `teff:"small,unit=bytes"`
+	}
+	value := limits{5 << 40, 86400, 2000}
+	text := "maxsize:\n\t5TiB\ntimeout:\n\t24h0m0s\nsmall:\n\t2KB"
+	buf, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != text {
+		t.Fatalf("expect \n%s\n    but got \n%s", text, buf)
+	}
+	var v limits
+	if err := Unmarshal(buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v != value {
+		t.Fatalf("expect %#v but got %#v", value, v)
+	}
+	for i, text := range []string{"small: 1MB", "small: 40000", "timeout: 1000000h", "maxsize: 10000000TB"} {
+		var v limits
+		if err := Unmarshal([]byte(text), &v); err == nil {
+			t.Fatalf("testcase %d: expect overflow error for %s", i, text)
+		}
+	}
+	var d struct {
+		Timeout int64 `teff:"timeout,unit=seconds"`
+	}
+	d.Timeout = 1e10
+	if _, err := Marshal(d); err == nil {
+		t.Fatal("expect overflow error for a duration")
+	}
+}